@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"cupid/logic"
+	"cupid/pkg/configs"
+	"cupid/pkg/region"
+	"cupid/pkg/scheduler"
+	"cupid/pkg/secrets"
+	"cupid/pkg/sensitive"
+	"cupid/pkg/utils"
+	"cupid/pkg/xhttp"
+	"cupid/resource"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// 生成和查看配置文件
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "生成和查看配置文件",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "init",
+				Usage: "交互式地生成配置文件",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{`o`},
+						Usage:   "指定生成的配置文件",
+						Value:   "./configs/configs.yaml",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "覆盖已存在的配置文件",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					output := c.String("output")
+					if utils.FileExist(output) && !c.Bool("force") {
+						return cli.Exit(fmt.Sprintf("%s already exists, use --force to overwrite it", output), 1)
+					}
+
+					wizard := &configWizard{prompt: os.Stderr, input: os.Stdin}
+					config, err := wizard.run(c.Context)
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					if err = writeConfig(output, config); err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					fmt.Printf("%s saved, run cupid sniff -c %s to start\n", output, output)
+					return nil
+				},
+			},
+			{
+				Name:  "show",
+				Usage: "输出生效的配置，隐藏 Token、Cookie 和接种人信息",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "conf",
+						Aliases:  []string{`c`},
+						Usage:    "指定配置文件",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "profile",
+						Aliases: []string{`p`},
+						Usage:   "指定配置组，输出应用配置组后的配置",
+					},
+					&cli.GenericFlag{
+						Name:  "set",
+						Usage: "覆盖配置项，格式为 key=value，可多次指定，如 --set ym.token=xxx",
+						Value: new(overrideFlag),
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := configs.SetOverrides(overrides(c)); err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					configs.UseProfile(c.String("profile"))
+
+					config, err := configs.Load(c.String("conf"))
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					if err = configs.Encode(os.Stdout, config, true); err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// 生成配置文件的向导
+type configWizard struct {
+	prompt io.Writer
+	input  io.Reader
+}
+
+// 向导中填写的配置项
+type wizardField struct {
+	path  string // YAML 路径，如 ym.token，同时作为保存到密钥文件时的名称
+	label string
+	value *string
+}
+
+// 是否为 Token、Cookie、身份证号等敏感信息，输入时不回显
+func (field wizardField) secret() bool {
+	return sensitive.Key(field.path[strings.LastIndex(field.path, ".")+1:])
+}
+
+// 依次询问区域、约苗和知苗易约的配置，返回校验通过的配置
+func (wizard *configWizard) run(ctx context.Context) (*configs.ServerConfig, error) {
+	catalog, err := loadCityCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := defaultConfig()
+	if config.Sniff.Regions, err = wizard.regions(catalog); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(wizard.prompt, "\n以下配置可直接回车跳过，敏感信息输入时不回显，可输入 %s名称 引用已保存的密钥\n", secrets.Scheme)
+
+	fields := []wizardField{
+		{"ym.token", "约苗 Token: ", &config.YM.Token},
+		{"ym.seckill_id", "约苗秒杀编号: ", &config.YM.SeckillID},
+		{"ym.linkman_id", "约苗接种人编号: ", &config.YM.LinkmanID},
+		{"ym.linkman_id_card", "约苗接种人身份证号: ", &config.YM.LinkmanIDCard},
+		{"zmyy.cookie", "知苗易约 Cookie: ", &config.ZMYY.Cookie},
+		{"zmyy.seckill_id", "知苗易约秒杀编号: ", &config.ZMYY.SeckillID},
+	}
+	for _, field := range fields {
+		if err = wizard.askField(field); err != nil {
+			return nil, err
+		}
+	}
+
+	// 校验失败时只重新询问有误的配置项
+	for {
+		err = config.Validate(wizardSections(config)...)
+		var validationErr *configs.ValidationError
+		if err == nil || !errors.As(err, &validationErr) {
+			break
+		}
+
+		invalid := make(map[string]bool)
+		for _, problem := range validationErr.Problems {
+			fmt.Fprintln(wizard.prompt, problem)
+			invalid[problem.Path] = true
+		}
+		asked := false
+		for _, field := range fields {
+			if invalid[field.path] {
+				if err = wizard.askField(field); err != nil {
+					return nil, err
+				}
+				asked = true
+			}
+		}
+		// 问题不在向导填写的配置项中，无法通过重新询问解决
+		if !asked {
+			return nil, validationErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = wizard.storeSecrets(config, fields); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// 只校验填写了的渠道，未填写的渠道可以之后再补充
+func wizardSections(config *configs.ServerConfig) []configs.Section {
+	sections := []configs.Section{configs.SectionLogger, configs.SectionHTTP, configs.SectionSniff, configs.SectionSeckill}
+	if config.YM != (configs.YMConfig{}) {
+		sections = append(sections, configs.SectionYM)
+	}
+	if config.ZMYY.Cookie != "" || config.ZMYY.SeckillID != "" {
+		sections = append(sections, configs.SectionZMYY)
+	}
+	return sections
+}
+
+// 询问配置项，敏感信息不回显
+func (wizard *configWizard) askField(field wizardField) (err error) {
+	if field.secret() {
+		*field.value, err = wizard.askSecret(field.label)
+	} else {
+		*field.value, err = wizard.ask(field.label)
+	}
+	return err
+}
+
+// 询问是否将填写的敏感信息保存到密钥文件，保存后配置文件中以 secret:// 引用
+func (wizard *configWizard) storeSecrets(config *configs.ServerConfig, fields []wizardField) error {
+	pending := make([]wizardField, 0, len(fields))
+	for _, field := range fields {
+		if field.secret() && *field.value != "" && !strings.HasPrefix(*field.value, secrets.Scheme) {
+			pending = append(pending, field)
+		}
+	}
+	if len(pending) <= 0 {
+		return nil
+	}
+
+	file := config.SecretsFile()
+	answer, err := wizard.ask(fmt.Sprintf("\n将 Token、Cookie 和接种人信息保存到密钥文件 %s，配置文件中以 %s名称 引用？[Y/n]: ", file, secrets.Scheme))
+	if err != nil {
+		return err
+	}
+	if answer = strings.ToLower(answer); answer != "" && answer != "y" && answer != "yes" {
+		fmt.Fprintln(wizard.prompt, "敏感信息将以明文写入配置文件")
+		return nil
+	}
+
+	// 新建密钥文件时口令需输入两次
+	credentialFromEnv := secrets.CredentialFromEnv
+	if !utils.FileExist(file) {
+		credentialFromEnv = secrets.NewCredentialFromEnv
+	}
+	credential, err := credentialFromEnv(wizard.prompt, wizard.input)
+	if err != nil {
+		return err
+	}
+	store, err := secrets.Open(file, credential)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range pending {
+		store.Set(field.path, *field.value)
+	}
+	if err = store.Save(); err != nil {
+		return err
+	}
+	for _, field := range pending {
+		*field.value = secrets.Scheme + field.path
+		fmt.Fprintf(wizard.prompt, "%s saved to %s\n", field.path, file)
+	}
+	return nil
+}
+
+// 选择省份和城市，返回区域规则
+func (wizard *configWizard) regions(catalog map[string]interface{}) ([]string, error) {
+	areas, err := region.Select(catalog, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按城市编码文件中的顺序列出省份
+	provinces := make([]string, 0)
+	cities := make(map[string][]string)
+	for _, area := range areas {
+		if _, ok := cities[area.Province]; !ok {
+			provinces = append(provinces, area.Province)
+		}
+		cities[area.Province] = append(cities[area.Province], area.City)
+	}
+
+	fmt.Fprintln(wizard.prompt, "省份：")
+	printChoices(wizard.prompt, provinces)
+	selected, err := wizard.choose("选择省份，多个编号以逗号分隔: ", provinces, false)
+	if err != nil {
+		return nil, err
+	}
+
+	regions := make([]string, 0, len(selected))
+	for _, province := range selected {
+		fmt.Fprintf(wizard.prompt, "\n%s的城市：\n", province)
+		printChoices(wizard.prompt, cities[province])
+		chosen, err := wizard.choose("选择城市，多个编号以逗号分隔，直接回车选择所有城市: ", cities[province], true)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(chosen) <= 0 {
+			regions = append(regions, province)
+			continue
+		}
+		for _, city := range chosen {
+			regions = append(regions, province+region.Separator+city)
+		}
+	}
+	return regions, nil
+}
+
+// 按编号选择，输入有误时重新询问，optional 为 true 时允许不选择
+func (wizard *configWizard) choose(label string, choices []string, optional bool) ([]string, error) {
+	for {
+		answer, err := wizard.ask(label)
+		if err != nil {
+			return nil, err
+		}
+
+		selected, err := parseChoices(answer, choices)
+		if err != nil {
+			fmt.Fprintln(wizard.prompt, err)
+			continue
+		}
+		if len(selected) <= 0 && !optional {
+			fmt.Fprintln(wizard.prompt, "至少选择一项")
+			continue
+		}
+		return selected, nil
+	}
+}
+
+// 询问一行输入
+func (wizard *configWizard) ask(label string) (string, error) {
+	answer, err := secrets.Prompt(wizard.prompt, wizard.input, label)
+	if errors.Is(err, io.EOF) {
+		return "", errors.New("configuration wizard aborted")
+	}
+	return strings.TrimSpace(answer), err
+}
+
+// 询问一行不回显的输入
+func (wizard *configWizard) askSecret(label string) (string, error) {
+	answer, err := secrets.PromptSecret(wizard.prompt, wizard.input, label)
+	if errors.Is(err, io.EOF) {
+		return "", errors.New("configuration wizard aborted")
+	}
+	return strings.TrimSpace(answer), err
+}
+
+// 解析以逗号或空格分隔的编号，编号从 1 开始
+func parseChoices(answer string, choices []string) ([]string, error) {
+	fields := strings.FieldsFunc(answer, func(r rune) bool {
+		return r == ',' || r == '，' || r == ' '
+	})
+
+	selected := make([]string, 0, len(fields))
+	seen := make(map[int]bool)
+	for _, field := range fields {
+		index, err := strconv.Atoi(field)
+		if err != nil || index < 1 || index > len(choices) {
+			return nil, fmt.Errorf("无效的编号 %q，应为 1 到 %d", field, len(choices))
+		}
+		if !seen[index] {
+			seen[index] = true
+			selected = append(selected, choices[index-1])
+		}
+	}
+	return selected, nil
+}
+
+// 输出带编号的选项
+func printChoices(w io.Writer, choices []string) {
+	for i, choice := range choices {
+		fmt.Fprintf(w, "  %3d. %s\n", i+1, choice)
+	}
+}
+
+// 读取城市编码文件，不存在时从约苗获取并保存
+func loadCityCodes(ctx context.Context) (map[string]interface{}, error) {
+	if utils.FileExist(resource.CityCodeFile) {
+		return utils.ReadJSONFromFile(resource.CityCodeFile)
+	}
+
+	catalog, err := logic.NewYMEngine(xhttp.NewClient(xhttp.DefaultOptions)).FetchCityCode(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get city code from api: %w", err)
+	}
+	if err = utils.WriteJSONToFile(resource.CityCodeFile, catalog); err != nil {
+		return nil, fmt.Errorf("unable to write city code to file: %w", err)
+	}
+	return catalog, nil
+}
+
+// 生成的配置文件中使用的默认值
+func defaultConfig() *configs.ServerConfig {
+	config := new(configs.ServerConfig)
+	config.Basic.SecretsFile = secrets.DefaultFile
+	config.Logger = configs.LoggerConfig{
+		Level:         "info",
+		Directory:     "./logs",
+		RotationTime:  24,
+		RotationCount: 7,
+		MaxSize:       100,
+		Compress:      true,
+		Redact:        true,
+		Console:       configs.ConsoleSinkConfig{Enabled: true, Color: true},
+		File:          configs.FileSinkConfig{Enabled: true},
+		Syslog:        configs.SyslogSinkConfig{Level: "warn", Tag: "cupid"},
+	}
+
+	options := xhttp.DefaultOptions
+	config.HTTP = configs.HTTPConfig{
+		Timeout:               options.Timeout,
+		DialTimeout:           options.DialTimeout,
+		TLSHandshakeTimeout:   options.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: options.ResponseHeaderTimeout,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		MaxResponseSize:       options.MaxResponseSize,
+	}
+
+	config.Sniff = configs.SniffConfig{
+		Vaccines:    []configs.VaccineRule{{Name: "九价", Keywords: []string{"九价"}, Excludes: []string{"预约登记"}}},
+		Workers:     4,
+		RatePerHost: 2,
+		Burst:       2,
+	}
+
+	timing := scheduler.DefaultTiming
+	config.Seckill.Timing = configs.TimingConfig{
+		Lead:   timing.Lead,
+		Window: configs.WindowConfig{Interval: timing.Window.Interval, Burst: timing.Window.Burst},
+		End:    timing.End,
+	}
+	for _, step := range timing.Ladder {
+		config.Seckill.Timing.Ladder = append(config.Seckill.Timing.Ladder, configs.LadderStep{Remaining: step.Remaining, Interval: step.Interval})
+	}
+
+	config.ZMYY.SessionFile = resource.ZMYYSessionFile
+	return config
+}
+
+// 写入配置文件
+func writeConfig(path string, config *configs.ServerConfig) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err = configs.Encode(file, config, false); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -14,5 +14,7 @@ require (
 	github.com/spf13/viper v1.9.0
 	github.com/urfave/cli/v2 v2.3.0
 	go.uber.org/zap v1.19.1
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
+	golang.org/x/sys v0.0.0-20210823070655-63515b42dcdf
 	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 )
@@ -1,9 +1,165 @@
 package logic
 
+import (
+	"context"
+	"cupid/pkg/clock"
+	"cupid/pkg/configs"
+	"cupid/pkg/region"
+	"cupid/pkg/scheduler"
+	"cupid/pkg/utils"
+	"cupid/pkg/vaccine"
+	"cupid/pkg/xhttp"
+	"cupid/resource"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
 type Engine interface {
-	// 探测哪些城市有秒杀信息
-	Sniff() ([]map[string]string, error)
+	// 探测哪些城市有秒杀信息，单个城市或医院失败时记录在报告中并继续嗅探，
+	// 上下文被取消或超时时返回包含部分结果的报告和上下文的错误
+	Sniff(ctx context.Context) (*SniffReport, error)
+
+	// 秒杀疫苗，上下文被取消时停止秒杀
+	SecKill(ctx context.Context) error
+
+	// 与渠道的服务器同步时钟
+	SyncClock(ctx context.Context) (*clock.Clock, error)
+}
+
+// 渠道
+type Source string
+
+// 获取渠道的中文名称
+func (source Source) String() string {
+	if registration, ok := Lookup(source); ok && registration.Name != "" {
+		return registration.Name
+	}
+	return string(source)
+}
+
+// 当前时间，用于解析和过滤秒杀信息，测试时可替换
+var timeNow = time.Now
+
+// 秒杀信息
+type SeckillInfo struct {
+	Source       Source                 // 渠道
+	SeckillID    string                 // 秒杀编号
+	Province     string                 // 省份
+	City         string                 // 城市
+	HospitalID   string                 // 医院编号
+	HospitalName string                 // 医院名称
+	VaccineName  string                 // 疫苗名称
+	VaccineType  string                 // 疫苗类型
+	Rule         string                 // 匹配的疫苗规则
+	StartTime    time.Time              // 开始时间
+	EndTime      time.Time              // 结束时间，部分渠道没有结束时间
+	Raw          map[string]interface{} // 原始数据
+}
+
+// 判断秒杀信息是否已过期
+func (info SeckillInfo) Expired(now time.Time) bool {
+	if !info.EndTime.IsZero() {
+		return info.EndTime.Before(now)
+	}
+	return info.StartTime.Before(now)
+}
+
+// 从原始数据中获取字符串类型的字段
+func rawString(raw map[string]interface{}, key string) string {
+	switch value := raw[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// 根据配置文件中的区域规则，从城市编码文件中选择待嗅探的区域，合并配置组时取各组的并集
+func selectAreas() ([]region.Area, error) {
+	if !utils.FileExist(resource.CityCodeFile) {
+		return nil, fmt.Errorf("city code file %s does not exist", resource.CityCodeFile)
+	}
+
+	allCityCodes, err := utils.ReadJSONFromFile(resource.CityCodeFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read city code file %s: %w", resource.CityCodeFile, err)
+	}
+
+	// 每组区域单独选择，排除规则只作用于所在的分组
+	groups := make([][]region.Area, 0)
+	for _, group := range configs.Get().RegionGroups() {
+		areas, err := region.Select(allCityCodes, group.Regions)
+		if err != nil {
+			if group.Profile != "" {
+				return nil, fmt.Errorf("profiles.%s.regions: %w", group.Profile, err)
+			}
+			return nil, err
+		}
+		groups = append(groups, areas)
+	}
+	return region.Union(groups...), nil
+}
+
+// 根据配置文件中的疫苗规则创建疫苗匹配器
+func newVaccineMatcher() (*vaccine.Matcher, error) {
+	rules := make([]vaccine.Rule, 0, len(configs.Get().Sniff.Vaccines))
+	for _, v := range configs.Get().Sniff.Vaccines {
+		rules = append(rules, vaccine.Rule{
+			Name:     v.Name,
+			Keywords: v.Keywords,
+			Regex:    v.Regex,
+			Excludes: v.Excludes,
+		})
+	}
+	return vaccine.NewMatcher(rules)
+}
+
+// 与渠道的服务器同步时钟，失败时使用本地时间，并记录使用的偏差
+func SyncEngineClock(ctx context.Context, engine Engine, name string) *clock.Clock {
+	serverClock, err := engine.SyncClock(ctx)
+	if err != nil {
+		zap.L().Warn(fmt.Sprintf("无法与%s的服务器同步时钟，使用本地时间", name), zap.Error(err))
+		return clock.Local()
+	}
+
+	zap.L().Info(fmt.Sprintf("已与%s的服务器同步时钟", name), zap.Duration("offset", serverClock.Offset()), zap.Duration("rtt", serverClock.RTT()))
+	return serverClock
+}
+
+// 根据配置创建各渠道共享的 HTTP 客户端
+func NewHTTPClient() *xhttp.Client {
+	config := configs.Get().HTTP
+	return xhttp.NewClient(xhttp.Options{
+		Timeout:               config.Timeout,
+		DialTimeout:           config.DialTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxResponseSize:       config.MaxResponseSize,
+	})
+}
+
+// 根据配置创建秒杀的倒计时调度器
+func newScheduler(serverClock scheduler.Clock) *scheduler.Scheduler {
+	config := configs.Get().Seckill.Timing
+
+	ladder := make([]scheduler.Step, 0, len(config.Ladder))
+	for _, step := range config.Ladder {
+		ladder = append(ladder, scheduler.Step{Remaining: step.Remaining, Interval: step.Interval})
+	}
 
-	// 秒杀疫苗
-	SecKill() error
+	return scheduler.New(scheduler.Timing{
+		Lead:   config.Lead,
+		Ladder: ladder,
+		Window: scheduler.Window{Interval: config.Window.Interval, Burst: config.Window.Burst},
+		End:    config.End,
+	}, serverClock)
 }
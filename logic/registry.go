@@ -0,0 +1,134 @@
+package logic
+
+import (
+	"cupid/pkg/configs"
+	"cupid/pkg/xhttp"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 引擎的能力
+type Capability uint8
+
+const (
+	CapabilitySniff   Capability = 1 << iota // 嗅探
+	CapabilitySeckill                        // 秒杀
+)
+
+// 获取能力的名称
+func (capability Capability) String() string {
+	names := make([]string, 0)
+	if capability&CapabilitySniff != 0 {
+		names = append(names, "sniff")
+	}
+	if capability&CapabilitySeckill != 0 {
+		names = append(names, "seckill")
+	}
+	return strings.Join(names, ",")
+}
+
+// 引擎的注册信息
+type Registration struct {
+	Source       Source                            // 渠道
+	Name         string                            // 渠道的中文名称
+	Capabilities Capability                        // 引擎的能力
+	Section      configs.Section                   // 秒杀时需要校验的配置分区
+	New          func(client *xhttp.Client) Engine // 创建使用指定 HTTP 客户端的引擎
+}
+
+// 判断引擎是否具备指定的能力
+func (registration Registration) Can(capability Capability) bool {
+	return registration.Capabilities&capability == capability
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[Source]Registration)
+)
+
+// 注册引擎，通常在引擎所在文件的 init 函数中调用
+func Register(registration Registration) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if registration.Source == "" || registration.New == nil {
+		panic("logic: invalid engine registration")
+	}
+	if _, exist := registry[registration.Source]; exist {
+		panic(fmt.Sprintf("logic: engine %q registered twice", registration.Source))
+	}
+	registry[registration.Source] = registration
+}
+
+// 获取指定渠道的引擎
+func Lookup(source Source) (Registration, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	registration, ok := registry[source]
+	return registration, ok
+}
+
+// 获取所有已注册的引擎，按渠道排序
+func Registrations() []Registration {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	registrations := make([]Registration, 0, len(registry))
+	for _, registration := range registry {
+		registrations = append(registrations, registration)
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].Source < registrations[j].Source
+	})
+	return registrations
+}
+
+// 获取所有已注册的渠道
+func Sources() []string {
+	sources := make([]string, 0)
+	for _, registration := range Registrations() {
+		sources = append(sources, string(registration.Source))
+	}
+	return sources
+}
+
+// 根据渠道列表选择具备指定能力的引擎，渠道列表为空时选择所有具备该能力的引擎
+func Select(sources []string, capability Capability) ([]Registration, error) {
+	registrations := make([]Registration, 0)
+
+	if len(sources) <= 0 {
+		for _, registration := range Registrations() {
+			if registration.Can(capability) {
+				registrations = append(registrations, registration)
+			}
+		}
+		if len(registrations) <= 0 {
+			return nil, fmt.Errorf("no engine supports %s", capability)
+		}
+		return registrations, nil
+	}
+
+	selected := make(map[Source]bool)
+	for _, v := range sources {
+		source := Source(strings.TrimSpace(v))
+		if source == "" || selected[source] {
+			continue
+		}
+
+		registration, ok := Lookup(source)
+		if !ok {
+			return nil, fmt.Errorf("unknown source %q, available sources: %s", source, strings.Join(Sources(), ","))
+		}
+		if !registration.Can(capability) {
+			return nil, fmt.Errorf("source %q does not support %s", source, capability)
+		}
+
+		selected[source] = true
+		registrations = append(registrations, registration)
+	}
+
+	return registrations, nil
+}
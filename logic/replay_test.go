@@ -0,0 +1,177 @@
+package logic
+
+import (
+	"context"
+	"cupid/pkg/configs"
+	"cupid/pkg/xhttp"
+	"cupid/resource"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 回放测试使用的当前时间，录制中的秒杀时间均相对于该时间
+var replayNow = time.Date(2021, 12, 10, 8, 0, 0, 0, time.Local)
+
+// 回放测试使用的配置，只嗅探四川省的九价疫苗
+const replayConfig = `
+sniff:
+  regions: ["四川省"]
+  vaccines:
+    - name: "九价"
+      keywords: ["九价"]
+      excludes: ["预约登记"]
+  workers: 2
+  rate_per_host: 50
+  burst: 10
+zmyy:
+  session_file: %q
+`
+
+// 创建回放 testdata/cassettes 中指定录制的客户端
+//
+// 城市编码文件和会话文件的路径均相对于工作目录，因此切换到临时目录，并写入配置文件和 testdata/city.json。
+func newReplayClient(t *testing.T, name string) *xhttp.Client {
+	t.Helper()
+
+	cassettes, err := filepath.Abs(filepath.Join("testdata", "cassettes", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cityCodes, err := ioutil.ReadFile(filepath.Join("testdata", "city.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err = ioutil.WriteFile(filepath.Join(dir, resource.CityCodeFile), cityCodes, 0644); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "configs.yaml")
+	if err = ioutil.WriteFile(configFile, []byte(fmt.Sprintf(replayConfig, filepath.Join(dir, "zmyy.json"))), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	timeNow = func() time.Time { return replayNow }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	if err = configs.ParseConfigFile(configFile, configs.SectionSniff); err != nil {
+		t.Fatal(err)
+	}
+
+	client := xhttp.NewClient(xhttp.DefaultOptions)
+	if err = client.Replay(cassettes); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestYMFetchCityCodeReplay(t *testing.T) {
+	want, err := ioutil.ReadFile(filepath.Join("testdata", "city.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newReplayClient(t, "ym")
+
+	cityCodes, err := NewYMEngine(client).FetchCityCode(context.Background())
+	if err != nil {
+		t.Fatalf("FetchCityCode() error = %v", err)
+	}
+
+	// 经过 JSON 编码后比较，与写入城市编码文件的内容一致
+	got, err := json.Marshal(cityCodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue interface{}
+	if err = json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("FetchCityCode() = %s, want %s", got, want)
+	}
+}
+
+func TestYMSniffReplay(t *testing.T) {
+	client := newReplayClient(t, "ym")
+
+	report, err := NewYMEngine(client).Sniff(context.Background())
+	if err != nil {
+		t.Fatalf("Sniff() error = %v", err)
+	}
+	if len(report.Errors) > 0 {
+		t.Fatalf("Sniff() errors = %v", report.Errors)
+	}
+
+	// 四价不匹配规则，六月份的秒杀已过期
+	if len(report.Results) != 1 {
+		t.Fatalf("Sniff() results = %+v, want 1 result", report.Results)
+	}
+	got := report.Results[0]
+	want := SeckillInfo{Source: SourceYM, SeckillID: "1276", Province: "四川省", City: "成都市", HospitalName: "成都市武侯区妇幼保健院", VaccineName: "九价人乳头瘤病毒疫苗", VaccineType: "8803", Rule: "九价"}
+	if got.Source != want.Source || got.SeckillID != want.SeckillID || got.Province != want.Province || got.City != want.City ||
+		got.HospitalName != want.HospitalName || got.VaccineName != want.VaccineName || got.VaccineType != want.VaccineType || got.Rule != want.Rule {
+		t.Errorf("Sniff() result = %+v, want %+v", got, want)
+	}
+	if startTime := replayNow.Add(time.Hour); !got.StartTime.Equal(startTime) {
+		t.Errorf("Sniff() start time = %v, want %v", got.StartTime, startTime)
+	}
+}
+
+func TestZMYYSniffReplay(t *testing.T) {
+	client := newReplayClient(t, "zmyy")
+
+	report, err := NewZMYYEngine(resource.ZMYYRootURL, client).Sniff(context.Background())
+	if err != nil {
+		t.Fatalf("Sniff() error = %v", err)
+	}
+	if len(report.Errors) > 0 {
+		t.Fatalf("Sniff() errors = %v", report.Errors)
+	}
+
+	// 四价暂无秒杀时间，预约登记被排除
+	if len(report.Results) != 1 {
+		t.Fatalf("Sniff() results = %+v, want 1 result", report.Results)
+	}
+	got := report.Results[0]
+	want := SeckillInfo{Source: SourceZMYY, SeckillID: "1", Province: "四川省", City: "成都市", HospitalID: "7", HospitalName: "成都市武侯区妇幼保健院", VaccineName: "九价人乳头瘤病毒疫苗", VaccineType: "九价", Rule: "九价"}
+	if got.Source != want.Source || got.SeckillID != want.SeckillID || got.Province != want.Province || got.City != want.City || got.HospitalID != want.HospitalID ||
+		got.HospitalName != want.HospitalName || got.VaccineName != want.VaccineName || got.VaccineType != want.VaccineType || got.Rule != want.Rule {
+		t.Errorf("Sniff() result = %+v, want %+v", got, want)
+	}
+	if startTime := time.Date(replayNow.Year(), 12, 31, 23, 58, 0, 0, time.Local); !got.StartTime.Equal(startTime) {
+		t.Errorf("Sniff() start time = %v, want %v", got.StartTime, startTime)
+	}
+}
+
+func TestSyncClockReplay(t *testing.T) {
+	client := newReplayClient(t, "zmyy")
+
+	// 录制的 Date 为 2021 年，回放时不应据此计算偏差
+	for _, engine := range []Engine{NewYMEngine(client), NewZMYYEngine(resource.ZMYYRootURL, client)} {
+		serverClock, err := engine.SyncClock(context.Background())
+		if err != nil {
+			t.Fatalf("SyncClock() error = %v", err)
+		}
+		if serverClock.Offset() != 0 {
+			t.Errorf("SyncClock() offset = %v, want 0", serverClock.Offset())
+		}
+	}
+}
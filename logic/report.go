@@ -0,0 +1,118 @@
+package logic
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 嗅探失败的城市或医院
+type SniffError struct {
+	Province string // 省份
+	City     string // 城市
+	Hospital string // 医院，为空时表示整个城市失败
+	Err      error  // 失败原因
+}
+
+func (e SniffError) Error() string {
+	if e.Hospital != "" {
+		return fmt.Sprintf("%s-%s-%s: %v", e.Province, e.City, e.Hospital, e.Err)
+	}
+	return fmt.Sprintf("%s-%s: %v", e.Province, e.City, e.Err)
+}
+
+func (e SniffError) Unwrap() error {
+	return e.Err
+}
+
+// 城市的嗅探耗时
+type SniffTiming struct {
+	Province string        // 省份
+	City     string        // 城市
+	Duration time.Duration // 耗时
+}
+
+// 嗅探报告，记录嗅探到的秒杀信息、失败的城市和医院以及耗时，可被多个协程同时写入
+type SniffReport struct {
+	Source    Source        // 渠道
+	Results   []SeckillInfo // 秒杀信息
+	Errors    []SniffError  // 失败的城市和医院
+	Succeeded int           // 成功探测的城市和医院的数量
+	Timings   []SniffTiming // 各城市的嗅探耗时
+	StartTime time.Time     // 开始时间
+	Duration  time.Duration // 总耗时
+
+	mutex sync.Mutex
+}
+
+// 创建嗅探报告
+func newSniffReport(source Source) *SniffReport {
+	return &SniffReport{
+		Source:    source,
+		Results:   make([]SeckillInfo, 0),
+		Errors:    make([]SniffError, 0),
+		Timings:   make([]SniffTiming, 0),
+		StartTime: time.Now(),
+	}
+}
+
+// 记录成功探测的城市或医院及其秒杀信息
+func (report *SniffReport) addSuccess(results ...SeckillInfo) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	report.Succeeded++
+	report.Results = append(report.Results, results...)
+}
+
+// 记录失败的城市或医院
+func (report *SniffReport) addError(e SniffError) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	report.Errors = append(report.Errors, e)
+}
+
+// 记录城市的嗅探耗时
+func (report *SniffReport) addTiming(province string, city string, startTime time.Time) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	report.Timings = append(report.Timings, SniffTiming{Province: province, City: city, Duration: time.Since(startTime)})
+}
+
+// 结束嗅探，记录总耗时
+func (report *SniffReport) finish() *SniffReport {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	report.Duration = time.Since(report.StartTime)
+	return report
+}
+
+// 失败的城市数量
+func (report *SniffReport) FailedCities() int {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	return report.failedCitiesLocked()
+}
+
+// 失败的医院数量
+func (report *SniffReport) FailedHospitals() int {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	return len(report.Errors) - report.failedCitiesLocked()
+}
+
+// 失败的城市数量，调用方需持有锁
+func (report *SniffReport) failedCitiesLocked() int {
+	count := 0
+	for _, e := range report.Errors {
+		if e.Hospital == "" {
+			count++
+		}
+	}
+	return count
+}
@@ -1,15 +1,20 @@
 package logic
 
 import (
+	"context"
+	"cupid/pkg/clock"
 	"cupid/pkg/configs"
+	"cupid/pkg/region"
+	"cupid/pkg/scheduler"
 	"cupid/pkg/utils"
+	"cupid/pkg/vaccine"
 	"cupid/pkg/xhttp"
 	"cupid/resource"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -20,201 +25,219 @@ import (
 	"go.uber.org/zap"
 )
 
+// 约苗的渠道
+const SourceYM Source = "ym"
+
+// 约苗等待秒杀期间重新同步时钟的间隔
+const ymClockResyncInterval = 10 * time.Minute
+
+// 休息时间不少于该值时才重新同步时钟，避免临近秒杀时同步耽误发起请求
+const ymClockResyncMinSleep = 5 * time.Second
+
+// 约苗各接口的重试策略
+var (
+	// 城市和秒杀列表：查询类接口，可以多次重试
+	ymListRetry = xhttp.RetryPolicy{MaxAttempts: 3, BaseDelay: 300 * time.Millisecond, MaxDelay: 2 * time.Second, Budget: 10 * time.Second}
+
+	// 订购：调度器会持续发起请求，因此只在被限流时短暂重试一次
+	ymSubscribeRetry = xhttp.RetryPolicy{MaxAttempts: 2, BaseDelay: 100 * time.Millisecond, MaxDelay: 200 * time.Millisecond, Budget: time.Second, JSONField: "msg", JSONValues: []string{"操作过于频繁,请稍后再试!"}}
+)
+
 // 约苗
-type YMEngine struct{}
+type YMEngine struct {
+	client *xhttp.Client // HTTP 客户端
+}
 
-// 获取约苗的引擎
-func GetYMEngine() *YMEngine {
-	return new(YMEngine)
+func init() {
+	Register(Registration{
+		Source:       SourceYM,
+		Name:         "约苗",
+		Capabilities: CapabilitySniff | CapabilitySeckill,
+		Section:      configs.SectionYM,
+		New: func(client *xhttp.Client) Engine {
+			return NewYMEngine(client)
+		},
+	})
+}
+
+// 创建使用指定 HTTP 客户端的约苗引擎
+func NewYMEngine(client *xhttp.Client) *YMEngine {
+	return &YMEngine{client: client}
 }
 
 // 探测哪些城市有秒杀信息
-func (engine *YMEngine) Sniff() (results []map[string]string, err error) {
-	// 获取城市的编码
-	var allCityCodes map[string]interface{}
-	if !utils.FileExist(resource.CityCodeFile) {
-		zap.L().Fatal("unable to get city code from file", zap.String("file", "city.json"))
-	} else {
-		allCityCodes, err = utils.ReadJSONFromFile(resource.CityCodeFile)
-		if err != nil {
-			zap.L().Error("unable to get city code from file", zap.String("file", resource.CityCodeFile), zap.Error(err))
-			return nil, err
-		}
-	}
+func (engine *YMEngine) Sniff(ctx context.Context) (*SniffReport, error) {
+	report := newSniffReport(SourceYM)
 
 	// 待嗅探的区域
-	var cityCodes map[string]interface{}
-	if len(configs.AllConfig.Sniff.Regions) <= 0 {
-		cityCodes = allCityCodes
-	} else {
-		cityCodes = make(map[string]interface{})
-
-		for _, region := range configs.AllConfig.Sniff.Regions {
-			regionSlice := strings.Split(region, "-")
-			if len(regionSlice) == 1 {
-				// 嗅探整个省或直辖市
-				if _, ok := allCityCodes[regionSlice[0]]; ok {
-					cityCodes[regionSlice[0]] = allCityCodes[regionSlice[0]]
-					continue
-				}
-			} else if len(regionSlice) == 2 {
-				// 嗅探指定市
-				if _, ok := allCityCodes[regionSlice[0]]; ok {
-					cityCodes[regionSlice[0]] = make([]interface{}, 0)
-				}
-
-				for _, v := range allCityCodes[regionSlice[0]].([]interface{}) {
-					city := v.(map[string]interface{})
-					if city["name"].(string) == regionSlice[1] {
-						cityCodes[regionSlice[0]] = append(cityCodes[regionSlice[0]].([]interface{}), city)
-						break
-					}
-				}
-
-				// 若未匹配到指定的市，则为空
-				if len(cityCodes[regionSlice[0]].([]interface{})) > 0 {
-					continue
-				} else {
-					delete(cityCodes, regionSlice[0])
-				}
-			}
-			zap.L().Error("未能正确匹配待嗅探的区域，请检查", zap.String("region", region))
-		}
+	areas, err := selectAreas()
+	if err != nil {
+		zap.L().Error("未能正确匹配待嗅探的区域，请检查", zap.Strings("regions", configs.Get().Sniff.Regions), zap.Error(err))
+		return report.finish(), err
 	}
 
-	// 多协程采集指标
-	channels := make(chan []map[string]string, len(cityCodes))
+	// 待嗅探的疫苗
+	matcher, err := newVaccineMatcher()
+	if err != nil {
+		zap.L().Error("未能正确解析疫苗的匹配规则，请检查", zap.Error(err))
+		return report.finish(), err
+	}
+	groups := region.GroupByProvince(areas)
 
 	// 限流器
 	limiter := rate.NewLimiter(rate.Every(500*time.Millisecond), 5)
 
 	// 协程管理器
 	var wg sync.WaitGroup
-	for province, cities := range cityCodes {
-		for {
-			if limiter.Allow() {
-				// 增加信号量，信号量 = 协程数量 = 集群数量
-				wg.Add(1)
-
-				item := cities.([]interface{})
-				go engine.hasSeckill(&wg, channels, province, item)
-				break
-			}
+	for province, items := range groups {
+		// 上下文被取消或超时，不再嗅探剩余的省份
+		if err = limiter.Wait(ctx); err != nil {
+			break
 		}
+
+		// 增加信号量，信号量 = 协程数量 = 集群数量
+		wg.Add(1)
+
+		go engine.hasSeckill(ctx, &wg, report, matcher, province, items)
 	}
 
 	// 等待所有协程结束
 	wg.Wait()
 
-	// 关闭通道
-	close(channels)
-
-	// 遍历通道
-	results = make([]map[string]string, 0)
-	for item := range channels {
-		results = append(results, item...)
+	// 返回已探测到的部分结果
+	if ctx.Err() != nil {
+		return report.finish(), ctx.Err()
 	}
 
-	return results, nil
+	return report.finish(), nil
 }
 
 // 秒杀
-func (engine *YMEngine) SecKill() error {
+func (engine *YMEngine) SecKill(ctx context.Context) error {
 	// 探测哪些城市有秒杀信息
 	zap.L().Info("正在嗅探约苗当前哪些城市有秒杀信息")
-	vaccines, err := engine.Sniff()
+	report, err := engine.Sniff(ctx)
 	if err != nil {
 		zap.L().Error("无法获取约苗当前哪些城市有秒杀信息", zap.Error(err))
 		return err
 	}
+	vaccines := report.Results
 
 	// 匹配待秒杀的疫苗
-	var vaccine map[string]string
-	for _, v := range vaccines {
-		if v["seckill"] == configs.AllConfig.YM.SeckillID {
-			vaccine = v
+	config := configs.Get().YM
+	var vaccine *SeckillInfo
+	for i := range vaccines {
+		if vaccines[i].SeckillID == config.SeckillID {
+			vaccine = &vaccines[i]
 			break
 		}
 	}
 
 	// 未匹配到指定的疫苗
 	if vaccine == nil {
-		zap.L().Error("未匹配到指定的疫苗", zap.String("seckill_id", configs.AllConfig.YM.SeckillID))
+		zap.L().Error("未匹配到指定的疫苗", zap.String("seckill_id", config.SeckillID))
 		return fmt.Errorf("未匹配到指定的疫苗")
 	}
 
-	// 解析秒杀时间
-	seckillStartTime := carbon.ParseByLayout(vaccine["start_time"], carbon.DateTimeFormat)
+	// 与服务器同步时钟，等待期间定期重新同步
+	serverClock := &ymResyncClock{Clock: SyncEngineClock(ctx, engine, "约苗"), engine: engine}
+	sched := newScheduler(serverClock)
 
-	headers := map[string]string{
-		"User-Agent": resource.UserAgent,
+	if vaccine.StartTime.Sub(serverClock.Now()) > time.Hour {
+		zap.L().Info("为防止请求Token过期，请在秒杀活动开始前1小时及时更新")
 	}
 
-	for {
-		data, err := xhttp.Do(resource.YMTimestampURL, http.MethodGet, headers, nil, nil)
-		if err != nil {
-			zap.L().Error("failed to do request", zap.Error(err))
-			return err
+	// 等待发射窗口打开
+	if err = sched.Wait(ctx, vaccine.StartTime); err != nil {
+		if errors.Is(err, scheduler.ErrWindowClosed) {
+			return fmt.Errorf("秒杀时间已过，欢迎下次使用")
 		}
+		zap.L().Info("小助手已停止等待秒杀活动", zap.Error(err))
+		return err
+	}
 
-		dataJSON, err := simplejson.NewJson(data)
-		if err != nil {
-			zap.L().Error("failed to unmarshal data", zap.Error(err))
-			return err
+	// 在发射窗口内订购疫苗，任意一个请求成功后停止发起请求
+	zap.L().Info("开始订购疫苗", zap.String("誓言", "两情若是久长时，又岂在朝朝暮暮"))
+	var succeeded int32
+	if _, err = sched.Fire(ctx, vaccine.StartTime, func(ctx context.Context) bool {
+		if engine.subscribeVaccine(ctx) {
+			atomic.StoreInt32(&succeeded, 1)
+			return true
 		}
+		return false
+	}); err != nil {
+		zap.L().Info("小助手已停止订购疫苗", zap.Error(err))
+		return err
+	}
 
-		serviceTimestamp := carbon.Now().TimestampWithMillisecond()
-		if dataJSON.Get("code").MustString() == resource.YMResponseOKCode {
-			serviceTimestamp = dataJSON.Get("data").MustInt64()
-		}
+	if atomic.LoadInt32(&succeeded) == 1 {
+		zap.L().Info("恭喜，疫苗订购成功", zap.String("hospital", vaccine.HospitalName), zap.String("vaccine", vaccine.VaccineName))
+		return nil
+	}
+	zap.L().Info("秒杀活动已结束，小助手自动退出")
+	return fmt.Errorf("秒杀活动已结束，订购失败")
+}
 
-		var seckillIntervalTime int64 = 400
-		diffInSeconds := serviceTimestamp/1000 - seckillStartTime.Timestamp()
-		diffInMilliseconds := serviceTimestamp - seckillStartTime.TimestampWithMillisecond()
-		if diffInMilliseconds >= 0 {
-			return fmt.Errorf("秒杀时间已过，欢迎下次使用")
-		} else if diffInMilliseconds >= -seckillIntervalTime {
-			zap.L().Info("开始订购疫苗", zap.String("誓言", "两情若是久长时，又岂在朝朝暮暮"))
-			break
-		} else if diffInMilliseconds >= -3*seckillIntervalTime {
-			zap.L().Info(fmt.Sprintf("将在离秒杀时间剩余%d毫秒时开始发起请求", seckillIntervalTime), zap.String("休息时间", fmt.Sprintf("%d毫秒", 50)), zap.String("剩余时间", fmt.Sprintf("%d毫秒", utils.Abs(diffInMilliseconds))))
-			time.Sleep(50 * time.Millisecond)
-		} else if diffInMilliseconds >= -3*1000 {
-			zap.L().Info(fmt.Sprintf("将在离秒杀时间剩余%d毫秒时开始发起请求", seckillIntervalTime), zap.String("休息时间", fmt.Sprintf("%d毫秒", 100)), zap.String("剩余时间", fmt.Sprintf("%d毫秒", utils.Abs(diffInMilliseconds))))
-			time.Sleep(100 * time.Millisecond)
-		} else if diffInMilliseconds >= -10*1000 {
-			zap.L().Info(fmt.Sprintf("将在离秒杀时间剩余%d毫秒时开始发起请求", seckillIntervalTime), zap.String("休息时间", fmt.Sprintf("%d秒", 1)), zap.String("剩余时间", fmt.Sprintf("%d秒", utils.Abs(diffInSeconds))))
-			time.Sleep(1 * time.Second)
-		} else if diffInMilliseconds >= -600*1000 {
-			zap.L().Info(fmt.Sprintf("将在离秒杀时间剩余%d毫秒时开始发起请求", seckillIntervalTime), zap.String("休息时间", fmt.Sprintf("%d秒", 5)), zap.String("剩余时间", fmt.Sprintf("%d秒", utils.Abs(diffInSeconds))))
-			time.Sleep(5 * time.Second)
-		} else if diffInMilliseconds >= -3600*1000 {
-			zap.L().Info(fmt.Sprintf("将在离秒杀时间剩余%d毫秒时开始发起请求", seckillIntervalTime), zap.String("休息时间", fmt.Sprintf("%d分", 5)), zap.String("剩余时间", fmt.Sprintf("%d秒", utils.Abs(diffInSeconds))))
-			time.Sleep(5 * time.Minute)
+// 等待秒杀期间定期与约苗的服务器重新同步的时钟
+type ymResyncClock struct {
+	*clock.Clock
+	engine *YMEngine
+}
+
+// 休眠指定的时间，距离上次同步超过间隔时重新同步
+func (serverClock *ymResyncClock) Sleep(ctx context.Context, duration time.Duration) error {
+	if err := serverClock.Clock.Sleep(ctx, duration); err != nil {
+		return err
+	}
+
+	if duration >= ymClockResyncMinSleep && serverClock.Age() > ymClockResyncInterval && !serverClock.engine.client.Replaying() {
+		if err := serverClock.Resync(ctx, serverClock.engine.clockSource, clock.DefaultSamples); err != nil {
+			zap.L().Warn("无法与约苗的服务器重新同步时钟，继续使用上次的偏差", zap.Error(err))
 		} else {
-			zap.L().Info("为防止请求Token过期，请在秒杀活动开始前1小时及时更新", zap.String("休息时间", fmt.Sprintf("%d分", 30)), zap.String("剩余时间", fmt.Sprintf("%d秒", utils.Abs(diffInSeconds))))
-			time.Sleep(30 * time.Minute)
+			zap.L().Info("已与约苗的服务器重新同步时钟", zap.Duration("offset", serverClock.Offset()), zap.Duration("rtt", serverClock.RTT()))
 		}
 	}
+	return nil
+}
 
-	// 限流器
-	limiter := rate.NewLimiter(rate.Every(250*time.Millisecond), 4)
-	for {
-		if limiter.Allow() {
-			go engine.subscribeVaccine()
-		}
+// 与约苗的服务器同步时钟
+func (engine *YMEngine) SyncClock(ctx context.Context) (*clock.Clock, error) {
+	// 回放时录制的时间戳与当前时间无关，使用本地时间
+	if engine.client.Replaying() {
+		return clock.Local(), nil
+	}
+	return clock.Sync(ctx, engine.clockSource, clock.DefaultSamples)
+}
 
-		if carbon.Now().DiffInSeconds(seckillStartTime) < -10 {
-			zap.L().Info("秒杀活动已结束，小助手自动退出")
-			break
-		}
+// 约苗的时间源：获取服务器的当前时间戳（毫秒）
+func (engine *YMEngine) clockSource(ctx context.Context) (time.Time, time.Duration, error) {
+	headers := map[string]string{
+		"User-Agent": resource.UserAgent,
 	}
 
-	return nil
+	data, err := engine.client.Do(ctx, resource.YMTimestampURL, http.MethodGet, headers, nil, nil)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	dataJSON, err := simplejson.NewJson(data)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	if dataJSON.Get("code").MustString() != resource.YMResponseOKCode {
+		return time.Time{}, 0, fmt.Errorf("unable to get timestamp, code: %s, msg: %s", dataJSON.Get("code").MustString(), dataJSON.Get("msg").MustString())
+	}
+
+	timestamp, err := dataJSON.Get("data").Int64()
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	return time.Unix(0, timestamp*int64(time.Millisecond)), time.Millisecond, nil
 }
 
 // 获取城市的编码
-func (engine *YMEngine) FetchCityCode() (map[string]interface{}, error) {
+func (engine *YMEngine) FetchCityCode(ctx context.Context) (map[string]interface{}, error) {
 	cityCodes := make(map[string]interface{})
 
 	headers := map[string]string{
@@ -222,7 +245,7 @@ func (engine *YMEngine) FetchCityCode() (map[string]interface{}, error) {
 	}
 
 	// 省份
-	data, err := xhttp.Do(resource.YMCityURL, http.MethodGet, headers, nil, nil)
+	data, err := engine.client.WithRetry(ymListRetry).Do(ctx, resource.YMCityURL, http.MethodGet, headers, nil, nil)
 	if err != nil {
 		zap.L().Error("failed to do request", zap.Error(err))
 		return nil, err
@@ -254,7 +277,7 @@ func (engine *YMEngine) FetchCityCode() (map[string]interface{}, error) {
 			}
 
 			// 获取经纬度
-			location := utils.GetLocation(province["name"].(string))
+			location := utils.GetLocation(ctx, engine.client, province["name"].(string))
 
 			cityCodes["直辖市"] = append(cityCodes["直辖市"].([]interface{}), map[string]interface{}{
 				"name":     province["name"].(string),
@@ -292,7 +315,7 @@ func (engine *YMEngine) FetchCityCode() (map[string]interface{}, error) {
 		queries := map[string]string{
 			"parentCode": province["value"].(string),
 		}
-		data, err = xhttp.Do(resource.YMCityURL, http.MethodGet, headers, queries, nil)
+		data, err = engine.client.WithRetry(ymListRetry).Do(ctx, resource.YMCityURL, http.MethodGet, headers, queries, nil)
 		if err != nil {
 			zap.L().Error("failed to do request", zap.Error(err))
 			return nil, err
@@ -312,10 +335,10 @@ func (engine *YMEngine) FetchCityCode() (map[string]interface{}, error) {
 			}
 
 			// 获取经纬度
-			location := utils.GetLocation(city["name"].(string))
+			location := utils.GetLocation(ctx, engine.client, city["name"].(string))
 			if location == nil {
 				// 若获取失败，则获取省份的经纬度
-				location = utils.GetLocation(province["name"].(string))
+				location = utils.GetLocation(ctx, engine.client, province["name"].(string))
 			}
 
 			cityCodes[province["name"].(string)] = append(cityCodes[province["name"].(string)].([]interface{}), map[string]interface{}{
@@ -330,115 +353,160 @@ func (engine *YMEngine) FetchCityCode() (map[string]interface{}, error) {
 }
 
 // 判断是否有秒杀信息
-func (engine *YMEngine) hasSeckill(wg *sync.WaitGroup, channels chan<- []map[string]string, province string, cities []interface{}) {
+func (engine *YMEngine) hasSeckill(ctx context.Context, wg *sync.WaitGroup, report *SniffReport, matcher *vaccine.Matcher, province string, areas []region.Area) {
 	// 协程管理信号量减一
 	defer wg.Done()
 
+	for i, area := range areas {
+		// 请求间隔，上下文被取消或超时时停止嗅探剩余的城市
+		if i > 0 {
+			if err := utils.Sleep(ctx, 500*time.Millisecond); err != nil {
+				return
+			}
+		}
+
+		startTime := time.Now()
+		result, err := engine.hasSeckillInCity(ctx, matcher, province, area)
+		report.addTiming(province, area.City, startTime)
+		if err != nil {
+			// 上下文被取消或超时导致的失败不计入失败的城市
+			if ctx.Err() != nil {
+				return
+			}
+			report.addError(SniffError{Province: province, City: area.City, Err: err})
+			continue
+		}
+		report.addSuccess(result...)
+	}
+}
+
+// 判断指定城市是否有秒杀信息
+func (engine *YMEngine) hasSeckillInCity(ctx context.Context, matcher *vaccine.Matcher, province string, area region.Area) ([]SeckillInfo, error) {
 	headers := map[string]string{
 		"User-Agent": resource.UserAgent,
 	}
 
-	result := make([]map[string]string, 0)
-	for _, v := range cities {
-		city := v.(map[string]interface{})
+	result := make([]SeckillInfo, 0)
 
-		if flag, ok := resource.Municipality[city["name"].(string)]; ok && flag {
-			city["value"] = fmt.Sprintf("%v01", city["value"])
-		}
+	regionCode := area.Code
+	if flag, ok := resource.Municipality[area.City]; ok && flag {
+		regionCode = fmt.Sprintf("%v01", area.Code)
+	}
 
-		if configs.AllConfig.Basic.Debug {
-			zap.L().Debug("当前探测的城市", zap.Any("province", province), zap.Any("city", city))
-		}
+	if configs.Get().Basic.Debug {
+		zap.L().Debug("当前探测的城市", zap.String("province", province), zap.String("city", area.City), zap.Strings("districts", area.Districts))
+	}
 
-		queries := map[string]string{
-			"regionCode": city["value"].(string),
-			"offset":     "0",
-			"limit":      "10",
-		}
+	queries := map[string]string{
+		"regionCode": regionCode,
+		"offset":     "0",
+		"limit":      "10",
+	}
 
-		data, err := xhttp.Do(resource.YMHasSeckillURL, http.MethodGet, headers, queries, nil)
-		if err != nil {
-			zap.L().Error("failed to do request", zap.Any("city", city), zap.Error(err))
-			return
-		}
+	data, err := engine.client.WithRetry(ymListRetry).Do(ctx, resource.YMHasSeckillURL, http.MethodGet, headers, queries, nil)
+	if err != nil {
+		zap.L().Error("failed to do request", zap.String("city", area.City), zap.Error(err))
+		return nil, err
+	}
 
-		dataJSON, err := simplejson.NewJson(data)
-		if err != nil {
-			zap.L().Error("failed to unmarshal data", zap.Any("city", city), zap.String("data", string(data)), zap.Error(err))
-			return
-		}
+	dataJSON, err := simplejson.NewJson(data)
+	if err != nil {
+		zap.L().Error("failed to unmarshal data", zap.String("city", area.City), zap.String("data", string(data)), zap.Error(err))
+		return nil, err
+	}
+
+	if dataJSON.Get("code").MustString() != resource.YMResponseOKCode || !dataJSON.Get("ok").MustBool() {
+		zap.L().Error("unable to get seckill info", zap.String("province", province), zap.String("city", area.City), zap.Any("data", dataJSON.MustMap()))
+		return nil, fmt.Errorf("unable to get seckill info, code: %s, msg: %s", dataJSON.Get("code").MustString(), dataJSON.Get("msg").MustString())
+	}
 
-		if dataJSON.Get("code").MustString() != resource.YMResponseOKCode || !dataJSON.Get("ok").MustBool() {
-			zap.L().Error("unable to get seckill info", zap.String("province", province), zap.Any("city", city), zap.Any("data", dataJSON.MustMap()), zap.Error(err))
-			return
+	for _, vv := range dataJSON.Get("data").MustArray() {
+		item := vv.(map[string]interface{})
+
+		// 解析秒杀时间
+		startTime := carbon.ParseByLayout(rawString(item, "startTime"), carbon.DateTimeFormat)
+		if startTime.Error != nil {
+			zap.L().Error("unable to parse start time", zap.String("city", area.City), zap.Any("item", item), zap.Error(startTime.Error))
+			continue
 		}
 
-		for _, vv := range dataJSON.Get("data").MustArray() {
-			item := vv.(map[string]interface{})
+		vaccine := SeckillInfo{
+			Source:       SourceYM,
+			SeckillID:    rawString(item, "id"),
+			Province:     province,
+			City:         area.City,
+			HospitalID:   rawString(item, "departmentCode"),
+			HospitalName: rawString(item, "name"),
+			VaccineName:  rawString(item, "vaccineName"),
+			VaccineType:  rawString(item, "vaccineCode"),
+			StartTime:    startTime.Carbon2Time(),
+			Raw:          item,
+		}
 
-			// 移除已过期的秒杀信息
-			if carbon.ParseByFormat(item["startTime"].(string), carbon.DateTimeFormat).ToTimestamp() < carbon.Now().ToTimestamp() {
-				continue
-			}
+		// 移除已过期的秒杀信息
+		if vaccine.Expired(timeNow()) {
+			continue
+		}
 
-			if vaccineName, ok := item["vaccineName"]; ok && strings.Contains(vaccineName.(string), "九价") {
-				vaccine := map[string]string{
-					"city":       city["name"].(string),             // 城市
-					"seckill":    item["id"].(json.Number).String(), // 秒杀编号
-					"vaccine":    vaccineName.(string),              // 疫苗名称
-					"hospital":   item["name"].(string),             // 医院名称
-					"start_time": item["startTime"].(string),        // 开始时间
-					"source":     "约苗",
-				}
-				result = append(result, vaccine)
-			} else if configs.AllConfig.Basic.Debug {
-				zap.L().Debug("当前城市的秒杀信息", zap.Any("city", city), zap.String("vaccine", vaccineName.(string)))
-				continue
-			}
+		// 约苗只能按城市查询，通过医院地址匹配指定的区县
+		if !area.MatchDistrict(rawString(item, "address")) {
+			continue
 		}
 
-		time.Sleep(500 * time.Millisecond)
+		if rule, ok := matcher.Match(vaccine.VaccineName); ok {
+			vaccine.Rule = rule
+			result = append(result, vaccine)
+		} else if configs.Get().Basic.Debug {
+			zap.L().Debug("当前城市的秒杀信息", zap.String("city", area.City), zap.String("vaccine", vaccine.VaccineName))
+			continue
+		}
 	}
 
-	channels <- result
+	return result, nil
 }
 
-// 订购疫苗
-func (engine *YMEngine) subscribeVaccine() {
+// 订购疫苗，订购成功时返回 true
+func (engine *YMEngine) subscribeVaccine(ctx context.Context) bool {
+	// 同一次请求使用同一份配置，Token 可在等待期间通过修改配置文件更新
+	config := configs.Get().YM
+
 	headers := map[string]string{
 		"User-Agent": resource.UserAgent,
-		"tk":         configs.AllConfig.YM.Token,
+		"tk":         config.Token,
 	}
 
 	query := map[string]string{
-		"seckillId":    configs.AllConfig.YM.SeckillID,
-		"linkmanId":    configs.AllConfig.YM.LinkmanID,
-		"idCardNo":     configs.AllConfig.YM.LinkmanIDCard,
+		"seckillId":    config.SeckillID,
+		"linkmanId":    config.LinkmanID,
+		"idCardNo":     config.LinkmanIDCard,
 		"vaccineIndex": "1",
 	}
 
-	data, err := xhttp.Do(resource.YMSubscribeURL, http.MethodGet, headers, query, nil)
+	data, err := engine.client.WithRetry(ymSubscribeRetry).Do(ctx, resource.YMSubscribeURL, http.MethodGet, headers, query, nil)
 	if err != nil {
 		zap.L().Error("failed to do request", zap.Error(err))
-		return
+		return false
 	}
 
 	dataJSON, err := simplejson.NewJson(data)
 	if err != nil {
 		zap.L().Error("failed to unmarshal data", zap.Error(err))
-		return
+		return false
 	}
 
 	zap.L().Info("发送请求成功", zap.Any("data", dataJSON.MustMap()))
 
-	if dataJSON.Get("code").MustString() != resource.YMResponseOKCode {
-		zap.L().Error("订购失败", zap.String("message", dataJSON.Get("msg").MustString()))
-		if dataJSON.Get("msg").MustString() == "操作过于频繁,请稍后再试!" {
-			time.Sleep(125 * time.Millisecond)
-		} else if dataJSON.Get("msg").MustString() == "用户登录超时,请重新登入!" {
-			time.Sleep(2 * time.Second)
-		} else if dataJSON.Get("msg").MustString() == "很抱歉 没抢到" {
-			time.Sleep(2 * time.Second)
-		}
+	if dataJSON.Get("code").MustString() == resource.YMResponseOKCode {
+		return true
+	}
+
+	zap.L().Error("订购失败", zap.String("message", dataJSON.Get("msg").MustString()))
+	if dataJSON.Get("msg").MustString() == "操作过于频繁,请稍后再试!" {
+		_ = utils.Sleep(ctx, 125*time.Millisecond)
+	} else if dataJSON.Get("msg").MustString() == "用户登录超时,请重新登入!" {
+		_ = utils.Sleep(ctx, 2*time.Second)
+	} else if dataJSON.Get("msg").MustString() == "很抱歉 没抢到" {
+		_ = utils.Sleep(ctx, 2*time.Second)
 	}
+	return false
 }
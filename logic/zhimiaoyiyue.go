@@ -1,210 +1,741 @@
 package logic
 
 import (
+	"context"
+	"cupid/pkg/clock"
 	"cupid/pkg/configs"
+	"cupid/pkg/region"
+	"cupid/pkg/scheduler"
 	"cupid/pkg/utils"
+	"cupid/pkg/vaccine"
 	"cupid/pkg/xhttp"
 	"cupid/resource"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bitly/go-simplejson"
+	"github.com/golang-module/carbon"
 
 	"go.uber.org/zap"
 )
 
+// 知苗易约的渠道
+const SourceZMYY Source = "zmyy"
+
+// 知苗易约嗅探时的默认并发数和每秒请求数
+const (
+	zmyyDefaultWorkers     = 4
+	zmyyDefaultRatePerHost = 2
+)
+
+// 知苗易约各接口的重试策略
+var (
+	// 医院和疫苗列表：查询类接口，可以多次重试
+	zmyySniffRetry = xhttp.RetryPolicy{MaxAttempts: 3, BaseDelay: 300 * time.Millisecond, MaxDelay: 2 * time.Second, Budget: 10 * time.Second}
+
+	// 秒杀期间的查询：重试的间隔较短，避免错过余量
+	zmyyQueryRetry = xhttp.RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 500 * time.Millisecond, Budget: 2 * time.Second}
+)
+
+// 知苗易约订单的状态
+const (
+	zmyyOrderPending        = 201                    // 订单正在处理中，需继续查询
+	zmyyOrderStatusAttempts = 10                     // 订单状态的最大查询次数
+	zmyyOrderStatusInterval = 500 * time.Millisecond // 订单状态的查询间隔
+)
+
 // 知苗易约
-type ZMYYEngine struct{}
+type ZMYYEngine struct {
+	rootURL string        // 接口地址，测试时可指向本地的模拟服务
+	client  *xhttp.Client // HTTP 客户端
+}
 
-// 获取知苗易约的引擎
-func GetZMYYEngine() *ZMYYEngine {
-	return new(ZMYYEngine)
+func init() {
+	Register(Registration{
+		Source:       SourceZMYY,
+		Name:         "知苗易约",
+		Capabilities: CapabilitySniff | CapabilitySeckill,
+		Section:      configs.SectionZMYY,
+		New: func(client *xhttp.Client) Engine {
+			return NewZMYYEngine(resource.ZMYYRootURL, client)
+		},
+	})
 }
 
-func (engine *ZMYYEngine) Sniff() (results []map[string]string, err error) {
-	// 获取城市的编码
-	var allCityCodes map[string]interface{}
-	if !utils.FileExist(resource.CityCodeFile) {
-		zap.L().Fatal("unable to get city code from file", zap.String("file", "city.json"))
-	} else {
-		allCityCodes, err = utils.ReadJSONFromFile(resource.CityCodeFile)
-		if err != nil {
-			zap.L().Error("unable to get city code from file", zap.String("file", resource.CityCodeFile), zap.Error(err))
-			return nil, err
-		}
-	}
+// 创建指定接口地址和 HTTP 客户端的知苗易约引擎
+func NewZMYYEngine(rootURL string, client *xhttp.Client) *ZMYYEngine {
+	return &ZMYYEngine{rootURL: rootURL, client: client.WithSigner(signZMYY)}
+}
+
+// 知苗易约的请求签名，每次请求（包括重试）时重新生成
+func signZMYY(request *http.Request) {
+	request.Header.Set("zftsl", utils.GetZFTSL())
+}
+
+// 探测哪些城市有秒杀信息
+func (engine *ZMYYEngine) Sniff(ctx context.Context) (*SniffReport, error) {
+	engine.loadSession()
+	defer engine.saveSession()
+
+	return engine.sniff(ctx)
+}
+
+// 探测哪些城市有秒杀信息，调用方负责恢复和保存会话
+func (engine *ZMYYEngine) sniff(ctx context.Context) (*SniffReport, error) {
+	report := newSniffReport(SourceZMYY)
 
 	// 待嗅探的区域
-	var cityCodes map[string]interface{}
-	if len(configs.AllConfig.Sniff.Regions) <= 0 {
-		cityCodes = allCityCodes
-	} else {
-		cityCodes = make(map[string]interface{})
-
-		for _, region := range configs.AllConfig.Sniff.Regions {
-			regionSlice := strings.Split(region, "-")
-
-			if len(regionSlice) == 1 {
-				// 嗅探整个省或直辖市
-				if _, ok := allCityCodes[regionSlice[0]]; ok {
-					cityCodes[regionSlice[0]] = allCityCodes[regionSlice[0]]
+	areas, err := selectAreas()
+	if err != nil {
+		zap.L().Error("未能正确匹配待嗅探的区域，请检查", zap.Strings("regions", configs.Get().Sniff.Regions), zap.Error(err))
+		return report.finish(), err
+	}
+
+	// 待嗅探的疫苗
+	matcher, err := newVaccineMatcher()
+	if err != nil {
+		zap.L().Error("未能正确解析疫苗的匹配规则，请检查", zap.Error(err))
+		return report.finish(), err
+	}
+
+	// 限流器：限制每个主机每秒的请求数，只作用于本次嗅探，不影响之后的秒杀
+	ratePerHost := configs.Get().Sniff.RatePerHost
+	if ratePerHost == 0 {
+		ratePerHost = zmyyDefaultRatePerHost
+	}
+	sniffer := &ZMYYEngine{
+		rootURL: engine.rootURL,
+		client:  engine.client.WithLimiter(xhttp.NewHostLimiter(ratePerHost, configs.Get().Sniff.Burst)),
+	}
+
+	// 协程池的大小
+	workers := configs.Get().Sniff.Workers
+	if workers <= 0 {
+		workers = zmyyDefaultWorkers
+	}
+
+	// 城市和医院分别由各自的协程池处理，城市协程池获取医院列表后交给医院协程池获取疫苗
+	cityTasks := make(chan zmyyCityTask)
+	hospitalTasks := make(chan zmyyHospitalTask)
+
+	var cityWG, hospitalWG sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		cityWG.Add(1)
+		go func() {
+			defer cityWG.Done()
+			for task := range cityTasks {
+				startTime := time.Now()
+				hospitals, err := sniffer.fetchHospitals(ctx, task)
+				report.addTiming(task.province, task.area.City+task.district, startTime)
+				if err != nil {
+					// 上下文被取消或超时导致的失败不计入失败的城市
+					if ctx.Err() != nil {
+						return
+					}
+					zap.L().Error("unable to get hospitals", zap.String("province", task.province), zap.String("city", task.area.City), zap.String("district", task.district), zap.Error(err))
+					report.addError(SniffError{Province: task.province, City: task.area.City + task.district, Err: err})
 					continue
 				}
-			} else if len(regionSlice) == 2 {
-				// 嗅探指定市
-				if _, ok := allCityCodes[regionSlice[0]]; ok {
-					cityCodes[regionSlice[0]] = make([]interface{}, 0)
-				}
+				report.addSuccess()
 
-				for _, v := range allCityCodes[regionSlice[0]].([]interface{}) {
-					city := v.(map[string]interface{})
-					if city["name"].(string) == regionSlice[1] {
-						cityCodes[regionSlice[0]] = append(cityCodes[regionSlice[0]].([]interface{}), city)
-						break
+				for _, hospital := range hospitals {
+					select {
+					case hospitalTasks <- zmyyHospitalTask{zmyyCityTask: task, hospital: hospital}:
+					case <-ctx.Done():
+						return
 					}
 				}
-
-				// 若未匹配到指定的市，则为空
-				if len(cityCodes[regionSlice[0]].([]interface{})) > 0 {
+			}
+		}()
+
+		hospitalWG.Add(1)
+		go func() {
+			defer hospitalWG.Done()
+			for task := range hospitalTasks {
+				vaccines, err := sniffer.fetchVaccines(ctx, matcher, task)
+				if err != nil {
+					if ctx.Err() != nil {
+						continue
+					}
+					zap.L().Error("unable to get vaccines", zap.String("province", task.province), zap.String("city", task.area.City), zap.String("hospital", rawString(task.hospital, "cname")), zap.Error(err))
+					report.addError(SniffError{Province: task.province, City: task.area.City + task.district, Hospital: rawString(task.hospital, "cname"), Err: err})
 					continue
-				} else {
-					delete(cityCodes, regionSlice[0])
+				}
+				report.addSuccess(vaccines...)
+			}
+		}()
+	}
+
+	// 分发城市任务，未指定区县时嗅探整个城市
+	go func() {
+		defer close(cityTasks)
+		for _, area := range areas {
+			districts := area.Districts
+			if len(districts) <= 0 {
+				districts = []string{""}
+			}
+			for _, district := range districts {
+				select {
+				case cityTasks <- zmyyCityTask{province: area.Province, area: area, district: district}:
+				case <-ctx.Done():
+					return
 				}
 			}
-			zap.L().Error("未能正确匹配待嗅探的区域，请检查", zap.String("region", region))
 		}
+	}()
+
+	// 所有城市处理完成后不再产生医院任务
+	cityWG.Wait()
+	close(hospitalTasks)
+	hospitalWG.Wait()
+
+	// 返回已探测到的部分结果
+	if ctx.Err() != nil {
+		return report.finish(), ctx.Err()
 	}
 
-	// 嗅探疫苗
-	results = make([]map[string]string, 0)
-	for province, cities := range cityCodes {
-		item := cities.([]interface{})
-		results = append(results, engine.hasSeckill(province, item)...)
+	return report.finish(), nil
+}
+
+// 秒杀
+func (engine *ZMYYEngine) SecKill(ctx context.Context) error {
+	engine.loadSession()
+	defer engine.saveSession()
+
+	// 等待期间修改配置文件中的 Cookie 时立即生效
+	unsubscribe := configs.Subscribe(func(previous *configs.ServerConfig, config *configs.ServerConfig) {
+		if config.ZMYY.Cookie != "" && config.ZMYY.Cookie != previous.ZMYY.Cookie {
+			engine.seedCookie(config.ZMYY.Cookie)
+			zap.L().Info("知苗易约的Cookie已更新")
+		}
+	})
+	defer unsubscribe()
+
+	if !engine.hasSession() {
+		return fmt.Errorf("知苗易约的Cookie为空，请先抓包获取")
 	}
 
-	return results, nil
+	// 探测哪些城市有秒杀信息
+	zap.L().Info("正在嗅探知苗易约当前哪些城市有秒杀信息")
+	report, err := engine.sniff(ctx)
+	if err != nil {
+		zap.L().Error("无法获取知苗易约当前哪些城市有秒杀信息", zap.Error(err))
+		return err
+	}
+	vaccines := report.Results
+
+	// 匹配待秒杀的疫苗
+	config := configs.Get().ZMYY
+	var vaccine *SeckillInfo
+	for i := range vaccines {
+		if vaccines[i].SeckillID != config.SeckillID {
+			continue
+		}
+		if config.HospitalID != "" && vaccines[i].HospitalID != config.HospitalID {
+			continue
+		}
+		vaccine = &vaccines[i]
+		break
+	}
+
+	// 未匹配到指定的疫苗
+	if vaccine == nil {
+		zap.L().Error("未匹配到指定的疫苗", zap.String("seckill_id", config.SeckillID), zap.String("hospital_id", config.HospitalID))
+		return fmt.Errorf("未匹配到指定的疫苗")
+	}
+	zap.L().Info("匹配到待秒杀的疫苗", zap.String("hospital", vaccine.HospitalName), zap.String("vaccine", vaccine.VaccineName), zap.Time("start_time", vaccine.StartTime), zap.Time("end_time", vaccine.EndTime))
+
+	// 获取接种人信息，Cookie 过期时无法获取
+	linkman, err := engine.fetchLinkman(ctx)
+	if err != nil {
+		zap.L().Error("无法获取知苗易约的接种人信息，请检查Cookie是否过期", zap.Error(err))
+		return err
+	}
+
+	// 与服务器同步时钟
+	serverClock := SyncEngineClock(ctx, engine, "知苗易约")
+	sched := newScheduler(serverClock)
+
+	if vaccine.StartTime.Sub(serverClock.Now()) > 2*time.Hour {
+		zap.L().Info("为防止Cookie过期，请在秒杀活动开始前2小时内及时更新")
+	}
+
+	// 等待发射窗口打开
+	if err = sched.Wait(ctx, vaccine.StartTime); err != nil {
+		if errors.Is(err, scheduler.ErrWindowClosed) {
+			return fmt.Errorf("秒杀时间已过，欢迎下次使用")
+		}
+		zap.L().Info("小助手已停止等待秒杀活动", zap.Error(err))
+		return err
+	}
+
+	// 在发射窗口内订购疫苗，任意一个请求成功后停止发起请求
+	zap.L().Info("开始订购疫苗", zap.String("誓言", "两情若是久长时，又岂在朝朝暮暮"))
+	var succeeded int32
+	if _, err = sched.Fire(ctx, vaccine.StartTime, func(ctx context.Context) bool {
+		if err := engine.subscribeVaccine(ctx, vaccine, linkman); err != nil {
+			zap.L().Error("订购失败", zap.Error(err))
+			return false
+		}
+		atomic.StoreInt32(&succeeded, 1)
+		return true
+	}); err != nil {
+		zap.L().Info("小助手已停止订购疫苗", zap.Error(err))
+		return err
+	}
+
+	if atomic.LoadInt32(&succeeded) == 1 {
+		zap.L().Info("恭喜，疫苗订购成功", zap.String("hospital", vaccine.HospitalName), zap.String("vaccine", vaccine.VaccineName))
+		return nil
+	}
+	zap.L().Info("秒杀活动已结束，小助手自动退出")
+	return fmt.Errorf("秒杀活动已结束，订购失败")
 }
 
-func (engine *ZMYYEngine) SecKill() error {
-	log.Println("ZMYYEngine's SecKill")
-	return nil
+// 与知苗易约的服务器同步时钟，知苗易约没有获取时间戳的接口，因此使用响应头中的 Date
+func (engine *ZMYYEngine) SyncClock(ctx context.Context) (*clock.Clock, error) {
+	// 回放时录制的 Date 与当前时间无关，使用本地时间
+	if engine.client.Replaying() {
+		return clock.Local(), nil
+	}
+	return clock.Sync(ctx, engine.clockSource, clock.DefaultSamples)
 }
 
-// 判断是否有秒杀信息
-func (engine *ZMYYEngine) hasSeckill(province string, cities []interface{}) []map[string]string {
+// 知苗易约的时间源：响应头中的 Date，精度为秒
+func (engine *ZMYYEngine) clockSource(ctx context.Context) (time.Time, time.Duration, error) {
 	headers := map[string]string{
 		"User-Agent": resource.UserAgent,
 		"Referer":    resource.ZMYYReferer,
 	}
 
-	results := make([]map[string]string, 0)
-	for _, v := range cities {
-		city := v.(map[string]interface{})
+	// 根地址可能返回非 2xx 的状态码，此时响应头中仍有 Date
+	_, header, err := engine.client.DoWithHeader(ctx, engine.rootURL, http.MethodGet, headers, nil, nil)
+	var statusErr *xhttp.StatusError
+	if err != nil && !errors.As(err, &statusErr) {
+		return time.Time{}, 0, err
+	}
+	return clock.HTTPDate(header)
+}
+
+// 知苗易约的接种人
+type zmyyLinkman struct {
+	Name     string // 姓名
+	Birthday string // 出生日期
+	Tel      string // 手机号
+	Sex      string // 性别
+	IDCard   string // 证件号码
+	DocType  string // 证件类型
+}
 
-		if flag, ok := resource.SpecialAdministrativeRegion[city["name"].(string)]; ok && flag {
-			city["value"] = fmt.Sprintf("%v01", city["value"])
-		} else {
-			city["value"] = fmt.Sprintf("%v00", city["value"])
-		}
+// 获取 Cookie 对应账号的接种人信息
+func (engine *ZMYYEngine) fetchLinkman(ctx context.Context) (*zmyyLinkman, error) {
+	dataJSON, err := engine.request(ctx, zmyyQueryRetry, map[string]string{"act": "User"})
+	if err != nil {
+		return nil, err
+	}
 
-		if configs.AllConfig.Basic.Debug {
-			zap.L().Debug("当前探测的城市", zap.Any("province", province), zap.Any("city", city))
-		}
+	user := dataJSON.Get("user").MustMap()
+	linkman := &zmyyLinkman{
+		Name:     rawString(user, "cname"),
+		Birthday: rawString(user, "birthday"),
+		Tel:      rawString(user, "tel"),
+		Sex:      rawString(user, "sex"),
+		IDCard:   rawString(user, "idcard"),
+		DocType:  rawString(user, "doctype"),
+	}
+	if linkman.Name == "" || linkman.IDCard == "" {
+		return nil, fmt.Errorf("接种人信息不完整，请先在小程序中完善个人信息")
+	}
+
+	return linkman, nil
+}
+
+// 订购疫苗：选择日期和时间段后提交订单，并查询订单状态
+func (engine *ZMYYEngine) subscribeVaccine(ctx context.Context, vaccine *SeckillInfo, linkman *zmyyLinkman) error {
+	// 选择预约日期
+	date, err := engine.chooseDate(ctx, vaccine)
+	if err != nil {
+		return err
+	}
 
-		location := city["location"].(interface{}).(map[string]interface{})
+	// 选择预约时间段
+	mxid, err := engine.chooseTime(ctx, vaccine, date)
+	if err != nil {
+		return err
+	}
+
+	// 提交订单
+	form := map[string]string{
+		"pid":      vaccine.SeckillID,
+		"mxid":     mxid,
+		"date":     date,
+		"cname":    linkman.Name,
+		"birthday": linkman.Birthday,
+		"tel":      linkman.Tel,
+		"sex":      linkman.Sex,
+		"idcard":   linkman.IDCard,
+		"doctype":  linkman.DocType,
+		"Ftime":    "1",
+		"guid":     "",
+	}
+	// 提交订单不是幂等的，因此不重试；接种人信息放在请求体中，避免出现在地址和访问日志里
+	if _, err = engine.submit(ctx, xhttp.NoRetry, "Save20", form); err != nil {
+		return err
+	}
 
-		// 设置请求头
-		headers["zftsl"] = utils.GetZFTSL()
+	// 提交成功不代表订购成功，需查询订单状态
+	return engine.waitOrder(ctx, date)
+}
 
-		queries := map[string]string{
-			"id":       "0",
-			"product":  "1",
-			"act":      "CustomerList",
-			"city":     fmt.Sprintf("[\"%s\",\"%s\",\"%s\"]", province, city["name"].(string), ""),
-			"cityCode": city["value"].(string),
-			"lat":      strconv.FormatFloat(location["lat"].(float64), 'f', -1, 64),
-			"lng":      strconv.FormatFloat(location["lng"].(float64), 'f', -1, 64),
+// 轮询订单状态，直到订单成功、失败或超出轮询次数
+func (engine *ZMYYEngine) waitOrder(ctx context.Context, date string) error {
+	for attempt := 1; ; attempt++ {
+		dataJSON, err := engine.request(ctx, zmyyQueryRetry, map[string]string{"act": "GetOrderStatus"})
+		if dataJSON == nil {
+			return err
+		}
+		zap.L().Info("订单状态", zap.String("date", date), zap.Int("attempt", attempt), zap.Any("data", dataJSON.MustMap()))
+
+		status := dataJSON.Get("status").MustInt()
+		switch {
+		case status == http.StatusOK:
+			return nil
+		case status != zmyyOrderPending:
+			return fmt.Errorf("订单失败: %w", err)
+		case attempt >= zmyyOrderStatusAttempts:
+			return fmt.Errorf("订单仍在处理中，已查询%d次: %s", attempt, dataJSON.Get("msg").MustString())
 		}
 
-		// 获取指定地区的医院列表
-		data, err := xhttp.Do(resource.ZMYYRootURL, http.MethodGet, headers, queries, nil)
-		if err != nil {
-			zap.L().Error("failed to do request", zap.Any("city", city["name"]), zap.Error(err))
-			return results
+		if err = utils.Sleep(ctx, zmyyOrderStatusInterval); err != nil {
+			return err
 		}
+	}
+}
 
-		dataJSON, err := simplejson.NewJson(data)
+// 选择预约日期，优先选择配置文件中指定的日期
+func (engine *ZMYYEngine) chooseDate(ctx context.Context, vaccine *SeckillInfo) (string, error) {
+	config := configs.Get().ZMYY
+
+	month := vaccine.StartTime.Format("200601")
+	if config.Date != "" {
+		date, err := time.ParseInLocation(carbon.DateFormat, config.Date, time.Local)
 		if err != nil {
-			zap.L().Error("failed to unmarshal data", zap.Any("city", city["name"]), zap.Error(err))
-			return results
+			return "", fmt.Errorf("invalid date %q: %w", config.Date, err)
 		}
+		month = date.Format("200601")
+	}
+
+	queries := map[string]string{
+		"act":   "GetCustomerSubscribeDateAll",
+		"id":    vaccine.HospitalID,
+		"pid":   vaccine.SeckillID,
+		"month": month,
+	}
+	dataJSON, err := engine.request(ctx, zmyyQueryRetry, queries)
+	if err != nil {
+		return "", err
+	}
 
-		if dataJSON.Get("status").MustInt() != http.StatusOK {
-			zap.L().Error("unable to get seckill info", zap.String("province", province), zap.Any("city", city["name"]), zap.Any("data", dataJSON.MustMap()), zap.Error(err))
-			return results
+	dates := make([]string, 0)
+	for _, v := range dataJSON.Get("list").MustArray() {
+		item, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if enable, _ := item["enable"].(bool); enable {
+			dates = append(dates, rawString(item, "date"))
 		}
+	}
 
-		// 医院列表
-		hospitals := dataJSON.Get("list").MustArray()
+	if len(dates) <= 0 {
+		return "", fmt.Errorf("暂无可预约的日期")
+	}
+	if config.Date == "" {
+		return dates[0], nil
+	}
+	for _, date := range dates {
+		if date == config.Date {
+			return date, nil
+		}
+	}
+	return "", fmt.Errorf("指定的日期 %s 不可预约，可预约的日期: %s", config.Date, strings.Join(dates, ","))
+}
 
-		for _, vv := range hospitals {
-			hospital := vv.(map[string]interface{})
+// 选择预约时间段，返回有余量的第一个时间段的编号
+func (engine *ZMYYEngine) chooseTime(ctx context.Context, vaccine *SeckillInfo, date string) (string, error) {
+	queries := map[string]string{
+		"act":    "GetCustomerSubscribeDateDetail",
+		"id":     vaccine.HospitalID,
+		"pid":    vaccine.SeckillID,
+		"scdate": date,
+	}
+	dataJSON, err := engine.request(ctx, zmyyQueryRetry, queries)
+	if err != nil {
+		return "", err
+	}
 
-			// 设置请求头
-			headers["zftsl"] = utils.GetZFTSL()
+	for _, v := range dataJSON.Get("list").MustArray() {
+		item, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if qty, err := strconv.Atoi(rawString(item, "qty")); err == nil && qty > 0 {
+			zap.L().Info("选择预约时间段", zap.String("date", date), zap.String("start_time", rawString(item, "StartTime")), zap.String("end_time", rawString(item, "EndTime")), zap.Int("qty", qty))
+			return rawString(item, "mxid"), nil
+		}
+	}
 
-			// 获取某医院内疫苗情况
-			productQueries := map[string]string{
-				"id":  hospital["id"].(json.Number).String(),
-				"act": "CustomerProduct",
-				"lat": strconv.FormatFloat(location["lat"].(float64), 'f', -1, 64),
-				"lng": strconv.FormatFloat(location["lng"].(float64), 'f', -1, 64),
-			}
+	return "", fmt.Errorf("%s 暂无可预约的时间段", date)
+}
 
-			// 获取指定医院的所有疫苗
-			productData, err := xhttp.Do(resource.ZMYYRootURL, http.MethodGet, headers, productQueries, nil)
-			if err != nil {
-				zap.L().Error("failed to do request", zap.Any("city", city["name"]), zap.Error(err))
-				continue
-			}
+// 会话文件的路径
+func (engine *ZMYYEngine) sessionFile() string {
+	if configs.Get().ZMYY.SessionFile != "" {
+		return configs.Get().ZMYY.SessionFile
+	}
+	return resource.ZMYYSessionFile
+}
 
-			productDataJSON, err := simplejson.NewJson(productData)
-			if err != nil {
-				zap.L().Error("failed to unmarshal data", zap.Any("city", city["name"]), zap.String("data", string(productData)), zap.Error(err))
-				continue
-			}
+// 使用配置文件中的 Cookie 初始化会话，并从会话文件中恢复上次运行时服务器下发的 Cookie
+func (engine *ZMYYEngine) loadSession() {
+	u, err := url.Parse(engine.rootURL)
+	if err != nil {
+		zap.L().Warn("知苗易约的接口地址无效，无法恢复会话", zap.String("url", engine.rootURL), zap.Error(err))
+		return
+	}
 
-			if productDataJSON.Get("status").MustInt() != http.StatusOK {
-				zap.L().Error("unable to get seckill info", zap.String("province", province), zap.Any("city", city["name"]), zap.Any("hospital", hospital), zap.Any("data", productDataJSON.MustMap()), zap.Error(err))
-				continue
-			}
+	if cookie := configs.Get().ZMYY.Cookie; cookie != "" {
+		engine.client.Jar().Seed(u, cookie)
+	}
+	if err = engine.client.Jar().Load(u, engine.sessionFile()); err != nil {
+		zap.L().Warn("无法恢复知苗易约的会话，使用配置文件中的Cookie", zap.String("file", engine.sessionFile()), zap.Error(err))
+	}
+}
 
-			for _, vvv := range productDataJSON.Get("list").MustArray() {
-				vaccine := vvv.(map[string]interface{})
-
-				if strings.Contains(vaccine["text"].(string), "九价") {
-					result := map[string]string{
-						"city":       city["name"].(string),                // 城市
-						"seckill":    vaccine["id"].(json.Number).String(), // 秒杀编号
-						"vaccine":    vaccine["text"].(string),             // 疫苗名称
-						"hospital":   hospital["cname"].(string),           // 医院名称
-						"start_time": vaccine["date"].(string),             // 开始时间
-						"source":     "知苗易约",                               // 渠道
-					}
-					results = append(results, result)
-				} else if configs.AllConfig.Basic.Debug {
-					zap.L().Debug("当前城市的秒杀信息", zap.Any("city", city["name"]), zap.String("vaccine", vaccine["text"].(string)))
-					continue
-				}
+// 使用配置文件中的 Cookie 覆盖会话中的同名 Cookie
+func (engine *ZMYYEngine) seedCookie(cookie string) {
+	u, err := url.Parse(engine.rootURL)
+	if err != nil {
+		return
+	}
+	engine.client.Jar().Seed(u, cookie)
+}
+
+// 将服务器下发的最新 Cookie 保存到会话文件中，供下次运行时使用
+func (engine *ZMYYEngine) saveSession() {
+	u, err := url.Parse(engine.rootURL)
+	if err != nil {
+		return
+	}
+	if err = engine.client.Jar().Save(u, engine.sessionFile()); err != nil {
+		zap.L().Warn("无法保存知苗易约的会话", zap.String("file", engine.sessionFile()), zap.Error(err))
+	}
+}
+
+// 是否有可用的 Cookie
+func (engine *ZMYYEngine) hasSession() bool {
+	u, err := url.Parse(engine.rootURL)
+	if err != nil {
+		return false
+	}
+	return len(engine.client.Jar().Cookies(u)) > 0
+}
+
+// 按指定的重试策略请求知苗易约的接口，并校验响应的状态码
+func (engine *ZMYYEngine) request(ctx context.Context, policy xhttp.RetryPolicy, queries map[string]string) (*simplejson.Json, error) {
+	return engine.do(ctx, policy, http.MethodGet, queries, nil)
+}
+
+// 以表单的形式向知苗易约的接口提交数据，接口名称仍通过 act 参数指定
+func (engine *ZMYYEngine) submit(ctx context.Context, policy xhttp.RetryPolicy, act string, form map[string]string) (*simplejson.Json, error) {
+	return engine.do(ctx, policy, http.MethodPost, map[string]string{"act": act}, xhttp.Form(form))
+}
+
+// 请求知苗易约的接口并校验响应的状态码
+func (engine *ZMYYEngine) do(ctx context.Context, policy xhttp.RetryPolicy, method string, queries map[string]string, body xhttp.Body) (*simplejson.Json, error) {
+	headers := map[string]string{
+		"User-Agent": resource.UserAgent,
+		"Referer":    resource.ZMYYReferer,
+	}
+
+	data, err := engine.client.WithRetry(policy).Do(ctx, engine.rootURL, method, headers, queries, body)
+	if err != nil {
+		return nil, err
+	}
+
+	dataJSON, err := simplejson.NewJson(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to unmarshal data: %w", queries["act"], err)
+	}
+
+	if status := dataJSON.Get("status").MustInt(); status != http.StatusOK {
+		return dataJSON, fmt.Errorf("%s: status %d, msg: %s", queries["act"], status, dataJSON.Get("msg").MustString())
+	}
+
+	return dataJSON, nil
+}
+
+// 知苗易约待嗅探的城市，区县为空时表示整个城市
+type zmyyCityTask struct {
+	province string
+	area     region.Area
+	district string
+}
+
+// 知苗易约待嗅探的医院
+type zmyyHospitalTask struct {
+	zmyyCityTask
+	hospital map[string]interface{}
+}
+
+// 获取指定城市的医院列表
+func (engine *ZMYYEngine) fetchHospitals(ctx context.Context, task zmyyCityTask) ([]map[string]interface{}, error) {
+	cityCode := fmt.Sprintf("%v00", task.area.Code)
+	if flag, ok := resource.SpecialAdministrativeRegion[task.area.City]; ok && flag {
+		cityCode = fmt.Sprintf("%v01", task.area.Code)
+	}
+
+	if configs.Get().Basic.Debug {
+		zap.L().Debug("当前探测的城市", zap.String("province", task.province), zap.String("city", task.area.City), zap.String("district", task.district))
+	}
+
+	queries := map[string]string{
+		"id":       "0",
+		"product":  "1",
+		"act":      "CustomerList",
+		"city":     fmt.Sprintf("[\"%s\",\"%s\",\"%s\"]", task.province, task.area.City, task.district),
+		"cityCode": cityCode,
+		"lat":      locationString(task.area.Location, "lat"),
+		"lng":      locationString(task.area.Location, "lng"),
+	}
+
+	dataJSON, err := engine.request(ctx, zmyySniffRetry, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	hospitals := make([]map[string]interface{}, 0)
+	for _, v := range dataJSON.Get("list").MustArray() {
+		if hospital, ok := v.(map[string]interface{}); ok {
+			hospitals = append(hospitals, hospital)
+		}
+	}
+	return hospitals, nil
+}
+
+// 获取指定医院中匹配的疫苗
+func (engine *ZMYYEngine) fetchVaccines(ctx context.Context, matcher *vaccine.Matcher, task zmyyHospitalTask) ([]SeckillInfo, error) {
+	queries := map[string]string{
+		"id":  rawString(task.hospital, "id"),
+		"act": "CustomerProduct",
+		"lat": locationString(task.area.Location, "lat"),
+		"lng": locationString(task.area.Location, "lng"),
+	}
+
+	dataJSON, err := engine.request(ctx, zmyySniffRetry, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SeckillInfo, 0)
+	for _, v := range dataJSON.Get("list").MustArray() {
+		product, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		vaccine := SeckillInfo{
+			Source:       SourceZMYY,
+			SeckillID:    rawString(product, "id"),
+			Province:     task.province,
+			City:         task.area.City,
+			HospitalID:   rawString(task.hospital, "id"),
+			HospitalName: rawString(task.hospital, "cname"),
+			VaccineName:  rawString(product, "text"),
+			VaccineType:  zmyyVaccineType(product),
+			Raw:          product,
+		}
+
+		// 解析秒杀时间，暂无秒杀时间的疫苗无法预约
+		vaccine.StartTime, vaccine.EndTime, err = parseZMYYDate(rawString(product, "date"), timeNow())
+		if err != nil {
+			if configs.Get().Basic.Debug {
+				zap.L().Debug("当前疫苗暂无秒杀时间", zap.String("city", task.area.City), zap.String("vaccine", vaccine.VaccineName), zap.Error(err))
 			}
-			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+
+		// 移除已过期的秒杀信息
+		if vaccine.Expired(timeNow()) {
+			continue
+		}
+
+		if rule, ok := matcher.Match(vaccine.VaccineName); ok {
+			vaccine.Rule = rule
+			results = append(results, vaccine)
+		} else if configs.Get().Basic.Debug {
+			zap.L().Debug("当前城市的秒杀信息", zap.String("city", task.area.City), zap.String("vaccine", vaccine.VaccineName))
+			continue
 		}
-		time.Sleep(1 * time.Second)
 	}
-	return results
+	return results, nil
+}
+
+// 疫苗名称中的价数，如"九价"、"4价"
+var valencePattern = regexp.MustCompile(`[一二三四九\d]+价`)
+
+// 获取知苗易约的疫苗类型，知苗易约没有疫苗类型的编码，从疫苗名称中提取价数，无法提取时使用疫苗的描述
+func zmyyVaccineType(product map[string]interface{}) string {
+	if valence := valencePattern.FindString(rawString(product, "text")); valence != "" {
+		return valence
+	}
+	return rawString(product, "descript")
+}
+
+// 获取经纬度的字符串形式
+func locationString(location map[string]interface{}, key string) string {
+	if value, ok := location[key].(float64); ok {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return rawString(location, key)
+}
+
+// 解析知苗易约的秒杀时间，格式为"12-03 17:05 至 12-03 17:10"，不包含年份
+func parseZMYYDate(value string, now time.Time) (startTime time.Time, endTime time.Time, err error) {
+	dateSlice := strings.Split(value, " 至 ")
+	if len(dateSlice) != 2 {
+		return startTime, endTime, fmt.Errorf("invalid date: %s", value)
+	}
+
+	layout := "2006-01-02 15:04"
+	startTime, err = time.ParseInLocation(layout, fmt.Sprintf("%d-%s", now.Year(), dateSlice[0]), time.Local)
+	if err != nil {
+		return startTime, endTime, err
+	}
+	endTime, err = time.ParseInLocation(layout, fmt.Sprintf("%d-%s", now.Year(), dateSlice[1]), time.Local)
+	if err != nil {
+		return startTime, endTime, err
+	}
+
+	// 跨年的秒杀信息，如十二月份发布次年一月份的秒杀，或一月份仍能查询到上一年十二月份的秒杀
+	if now.Sub(startTime) > 180*24*time.Hour {
+		startTime = startTime.AddDate(1, 0, 0)
+		endTime = endTime.AddDate(1, 0, 0)
+	} else if startTime.Sub(now) > 180*24*time.Hour {
+		startTime = startTime.AddDate(-1, 0, 0)
+		endTime = endTime.AddDate(-1, 0, 0)
+	}
+	if endTime.Before(startTime) {
+		endTime = endTime.AddDate(1, 0, 0)
+	}
+
+	return startTime, endTime, nil
 }
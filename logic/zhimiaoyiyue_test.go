@@ -0,0 +1,215 @@
+package logic
+
+import (
+	"context"
+	"cupid/pkg/xhttp"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 知苗易约的本地模拟服务，按 act 返回响应，并记录各接口的请求次数
+type zmyyStandIn struct {
+	mutex     sync.Mutex
+	calls     map[string]int
+	methods   map[string]string
+	queries   map[string]map[string]string
+	responses map[string][]map[string]interface{} // 同一接口多次请求时依次返回，最后一个响应重复使用
+}
+
+func (standIn *zmyyStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	standIn.mutex.Lock()
+	defer standIn.mutex.Unlock()
+
+	// 提交的表单与地址中的参数一并记录
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	act := r.Form.Get("act")
+	standIn.calls[act]++
+	standIn.methods[act] = r.Method
+	standIn.queries[act] = make(map[string]string)
+	for k := range r.Form {
+		standIn.queries[act][k] = r.Form.Get(k)
+	}
+
+	responses := standIn.responses[act]
+	if len(responses) <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	index := standIn.calls[act] - 1
+	if index >= len(responses) {
+		index = len(responses) - 1
+	}
+	_ = json.NewEncoder(w).Encode(responses[index])
+}
+
+// 创建指向模拟服务的知苗易约引擎
+func newZMYYStandIn(t *testing.T, responses map[string][]map[string]interface{}) (*ZMYYEngine, *zmyyStandIn) {
+	t.Helper()
+
+	standIn := &zmyyStandIn{
+		calls:     make(map[string]int),
+		methods:   make(map[string]string),
+		queries:   make(map[string]map[string]string),
+		responses: responses,
+	}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	return NewZMYYEngine(server.URL+"/sc/api/cust/", xhttp.NewClient(xhttp.DefaultOptions)), standIn
+}
+
+// 订购前选择日期和时间段的响应
+func zmyyBookingResponses(orderStatus ...map[string]interface{}) map[string][]map[string]interface{} {
+	return map[string][]map[string]interface{}{
+		"User": {{"status": 200, "user": map[string]interface{}{
+			"cname": "张三", "birthday": "1998-01-11", "tel": "13800000000", "sex": 2, "idcard": "510125199801116024", "doctype": 1,
+		}}},
+		"GetCustomerSubscribeDateAll":    {{"status": 200, "list": []interface{}{map[string]interface{}{"date": "2021-12-10", "enable": true}}}},
+		"GetCustomerSubscribeDateDetail": {{"status": 200, "list": []interface{}{map[string]interface{}{"mxid": "mx01", "qty": 5}}}},
+		"Save20":                         {{"status": 200, "msg": "提交成功"}},
+		"GetOrderStatus":                 orderStatus,
+	}
+}
+
+func TestZMYYSubscribeVaccine(t *testing.T) {
+	vaccine := &SeckillInfo{Source: SourceZMYY, SeckillID: "1", HospitalID: "7", StartTime: time.Date(2021, 12, 10, 9, 0, 0, 0, time.Local)}
+
+	cases := []struct {
+		name        string
+		orderStatus []map[string]interface{}
+		wantErr     string
+		wantQueries int
+	}{
+		{
+			name:        "success",
+			orderStatus: []map[string]interface{}{{"status": 200, "msg": "预约成功"}},
+			wantQueries: 1,
+		},
+		{
+			name:        "pending then success",
+			orderStatus: []map[string]interface{}{{"status": zmyyOrderPending, "msg": "排队中"}, {"status": 200, "msg": "预约成功"}},
+			wantQueries: 2,
+		},
+		{
+			name:        "rejected",
+			orderStatus: []map[string]interface{}{{"status": 500, "msg": "名额已满"}},
+			wantErr:     "名额已满",
+			wantQueries: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			engine, standIn := newZMYYStandIn(t, zmyyBookingResponses(c.orderStatus...))
+			ctx := context.Background()
+
+			linkman, err := engine.fetchLinkman(ctx)
+			if err != nil {
+				t.Fatalf("fetchLinkman() error = %v", err)
+			}
+
+			err = engine.subscribeVaccine(ctx, vaccine, linkman)
+			if c.wantErr == "" && err != nil {
+				t.Fatalf("subscribeVaccine() error = %v", err)
+			}
+			if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Fatalf("subscribeVaccine() error = %v, want %q", err, c.wantErr)
+			}
+			if got := standIn.calls["GetOrderStatus"]; got != c.wantQueries {
+				t.Errorf("GetOrderStatus called %d times, want %d", got, c.wantQueries)
+			}
+
+			if standIn.methods["Save20"] != http.MethodPost {
+				t.Errorf("Save20 method = %s, want %s", standIn.methods["Save20"], http.MethodPost)
+			}
+			save := standIn.queries["Save20"]
+			if save["pid"] != "1" || save["mxid"] != "mx01" || save["date"] != "2021-12-10" || save["idcard"] != "510125199801116024" {
+				t.Errorf("unexpected Save20 queries: %v", save)
+			}
+		})
+	}
+}
+
+func TestZMYYSubscribeVaccineSaveFailed(t *testing.T) {
+	responses := zmyyBookingResponses(map[string]interface{}{"status": 200})
+	responses["Save20"] = []map[string]interface{}{{"status": 408, "msg": "请求过于频繁"}}
+	engine, standIn := newZMYYStandIn(t, responses)
+
+	vaccine := &SeckillInfo{Source: SourceZMYY, SeckillID: "1", HospitalID: "7", StartTime: time.Now()}
+	err := engine.subscribeVaccine(context.Background(), vaccine, &zmyyLinkman{Name: "张三", IDCard: "510125199801116024"})
+	if err == nil || !strings.Contains(err.Error(), "请求过于频繁") {
+		t.Fatalf("subscribeVaccine() error = %v, want Save20 failure", err)
+	}
+	if standIn.calls["Save20"] != 1 {
+		t.Errorf("Save20 called %d times, want exactly 1", standIn.calls["Save20"])
+	}
+	if standIn.calls["GetOrderStatus"] != 0 {
+		t.Errorf("GetOrderStatus called %d times after a failed Save20", standIn.calls["GetOrderStatus"])
+	}
+}
+
+func TestParseZMYYDate(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.Local)
+	}
+	cases := []struct {
+		name  string
+		value string
+		now   time.Time
+		start time.Time
+		end   time.Time
+	}{
+		{
+			name:  "same year",
+			value: "12-10 09:00 至 12-10 18:00",
+			now:   at(2021, 12, 1, 8, 0),
+			start: at(2021, 12, 10, 9, 0),
+			end:   at(2021, 12, 10, 18, 0),
+		},
+		{
+			name:  "next year in december",
+			value: "01-05 09:00 至 01-05 18:00",
+			now:   at(2021, 12, 20, 8, 0),
+			start: at(2022, 1, 5, 9, 0),
+			end:   at(2022, 1, 5, 18, 0),
+		},
+		{
+			name:  "last year in january",
+			value: "12-31 23:58 至 12-31 23:59",
+			now:   at(2022, 1, 2, 8, 0),
+			start: at(2021, 12, 31, 23, 58),
+			end:   at(2021, 12, 31, 23, 59),
+		},
+		{
+			name:  "range across the new year",
+			value: "12-31 09:00 至 01-01 18:00",
+			now:   at(2021, 12, 20, 8, 0),
+			start: at(2021, 12, 31, 9, 0),
+			end:   at(2022, 1, 1, 18, 0),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			start, end, err := parseZMYYDate(c.value, c.now)
+			if err != nil {
+				t.Fatalf("parseZMYYDate() error = %v", err)
+			}
+			if !start.Equal(c.start) || !end.Equal(c.end) {
+				t.Errorf("parseZMYYDate() = %v, %v, want %v, %v", start, end, c.start, c.end)
+			}
+		})
+	}
+
+	if _, _, err := parseZMYYDate("暂无", time.Now()); err == nil {
+		t.Error("parseZMYYDate() error = nil for a missing date")
+	}
+}
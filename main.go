@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"cupid/logic"
 	"cupid/pkg/configs"
 	"cupid/pkg/logger"
 	"cupid/pkg/utils"
+	"cupid/pkg/xhttp"
 	"cupid/resource"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-agumon/table"
+	"github.com/go-agumon/table/models"
 	"github.com/golang-module/carbon"
 
 	"go.uber.org/zap"
@@ -56,9 +63,44 @@ func main() {
 					Value:    "",
 					Required: true,
 				},
+				&cli.StringSliceFlag{
+					Name:    "source",
+					Aliases: []string{`s`},
+					Usage:   "指定渠道，多个渠道以逗号分隔，如 ym,zmyy，默认为所有渠道",
+				},
+				&cli.StringFlag{
+					Name:  "record",
+					Usage: "将请求和脱敏后的响应录制到指定目录，用于离线回放",
+				},
+				&cli.StringFlag{
+					Name:  "replay",
+					Usage: "从指定目录回放录制的响应，不发起真实的请求",
+				},
+				&cli.StringFlag{
+					Name:    "profile",
+					Aliases: []string{`p`},
+					Usage:   "指定配置组，使用配置组中的凭证、接种人、秒杀目标和区域",
+				},
+				&cli.GenericFlag{
+					Name:  "set",
+					Usage: "覆盖配置项，格式为 key=value，可多次指定，如 --set ym.token=xxx",
+					Value: new(overrideFlag),
+				},
+				&cli.DurationFlag{
+					Name:    "timeout",
+					Aliases: []string{`t`},
+					Usage:   "指定嗅探的总时长，超时后输出已探测到的部分结果，如 5m，默认不限制",
+				},
 			},
 			Action: func(c *cli.Context) error {
-				if err := SniffService(c.String("conf")); err != nil {
+				ctx := c.Context
+				if timeout := c.Duration("timeout"); timeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, timeout)
+					defer cancel()
+				}
+
+				if err := SniffService(ctx, newServiceOptions(c)); err != nil {
 					return cli.Exit(err.Error(), 1)
 				}
 				return nil
@@ -75,28 +117,135 @@ func main() {
 					Value:    "",
 					Required: true,
 				},
+				&cli.StringSliceFlag{
+					Name:    "source",
+					Aliases: []string{`s`},
+					Usage:   "指定渠道，多个渠道以逗号分隔，如 ym,zmyy，默认为约苗",
+				},
+				&cli.StringFlag{
+					Name:  "record",
+					Usage: "将请求和脱敏后的响应录制到指定目录，用于离线回放",
+				},
+				&cli.StringFlag{
+					Name:  "replay",
+					Usage: "从指定目录回放录制的响应，不发起真实的请求",
+				},
+				&cli.StringFlag{
+					Name:    "profile",
+					Aliases: []string{`p`},
+					Usage:   "指定配置组，使用配置组中的凭证、接种人、秒杀目标和区域",
+				},
+				&cli.GenericFlag{
+					Name:  "set",
+					Usage: "覆盖配置项，格式为 key=value，可多次指定，如 --set ym.token=xxx",
+					Value: new(overrideFlag),
+				},
 			},
 			Action: func(c *cli.Context) error {
-				if err := SeckillService(c.String("conf")); err != nil {
+				if err := SeckillService(c.Context, newServiceOptions(c)); err != nil {
 					return cli.Exit(err.Error(), 1)
 				}
 				return nil
 			},
 		},
+		secretsCommand(),
+		configCommand(),
 	}
 
+	// 收到中断信号时取消上下文，让正在执行的请求和等待及时退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 运行服务
-	err := app.Run(os.Args)
+	err := app.RunContext(ctx, os.Args)
 	if err != nil {
 		fmt.Printf("Service failed to start, err: [%s]\n", err.Error())
 		os.Exit(1)
 	}
 }
 
+// 服务的命令行参数
+type ServiceOptions struct {
+	ConfigFile string   // 配置文件
+	Sources    []string // 渠道
+	Record     string   // 录制请求的目录
+	Replay     string   // 回放请求的目录
+	Overrides  []string // 覆盖的配置项
+	Profile    string   // 配置组
+}
+
+// 可多次指定的 key=value 参数，与 StringSliceFlag 不同，不会以逗号分隔值
+type overrideFlag struct {
+	values []string
+}
+
+func (flag *overrideFlag) Set(value string) error {
+	flag.values = append(flag.values, value)
+	return nil
+}
+
+func (flag *overrideFlag) String() string {
+	if flag == nil {
+		return ""
+	}
+	return strings.Join(flag.values, " ")
+}
+
+// 从命令行参数中获取服务的参数
+func newServiceOptions(c *cli.Context) ServiceOptions {
+	return ServiceOptions{
+		ConfigFile: c.String("conf"),
+		Sources:    c.StringSlice("source"),
+		Record:     c.String("record"),
+		Replay:     c.String("replay"),
+		Overrides:  overrides(c),
+		Profile:    c.String("profile"),
+	}
+}
+
+// 获取 --set 指定的配置项
+func overrides(c *cli.Context) []string {
+	if flag, ok := c.Generic("set").(*overrideFlag); ok && flag != nil {
+		return flag.values
+	}
+	return nil
+}
+
+// 创建各渠道共享的 HTTP 客户端，按参数录制或回放请求
+func newHTTPClient(options ServiceOptions) (*xhttp.Client, error) {
+	client := logic.NewHTTPClient()
+
+	switch {
+	case options.Record != "" && options.Replay != "":
+		return nil, fmt.Errorf("--record and --replay cannot be used together")
+	case options.Record != "":
+		if err := client.Record(options.Record); err != nil {
+			return nil, err
+		}
+		zap.L().Info("正在录制请求", zap.String("dir", options.Record))
+	case options.Replay != "":
+		if err := client.Replay(options.Replay); err != nil {
+			return nil, err
+		}
+		zap.L().Info("正在回放录制的请求", zap.String("dir", options.Replay))
+	}
+
+	return client, nil
+}
+
 // 探测哪些城市有秒杀信息
-func SniffService(configFile string) (err error) {
-	// 解析配置文件
-	if err = configs.ParseConfigFile(configFile); err != nil {
+func SniffService(ctx context.Context, options ServiceOptions) (err error) {
+	// 命令行参数的优先级最高
+	if err = configs.SetOverrides(options.Overrides); err != nil {
+		return err
+	}
+
+	// 未指定配置组时嗅探所有配置组关注的区域
+	configs.UseProfile(options.Profile)
+	configs.MergeProfileRegions(options.Profile == "")
+
+	// 解析配置文件，嗅探只需要日志、HTTP 客户端和嗅探的配置
+	if err = configs.ParseConfigFile(options.ConfigFile, configs.SectionLogger, configs.SectionHTTP, configs.SectionSniff); err != nil {
 		return err
 	}
 
@@ -107,13 +256,27 @@ func SniffService(configFile string) (err error) {
 	// 延迟注册：将缓存区的日志追加到日志文件中
 	defer logger.Sync()
 
+	// 待嗅探的渠道
+	engines, err := logic.Select(options.Sources, logic.CapabilitySniff)
+	if err != nil {
+		zap.L().Error("unable to select engines", zap.Strings("sources", options.Sources), zap.Error(err))
+		return err
+	}
+
+	// 各渠道共享 HTTP 客户端的连接池
+	client, err := newHTTPClient(options)
+	if err != nil {
+		zap.L().Error("unable to create http client", zap.Error(err))
+		return err
+	}
+
 	// 创建表格
-	seckillTable, _ := table.Create("渠道", "城市", "医院", "疫苗", "秒杀时间", "秒杀编号")
+	seckillTable, _ := table.Create("渠道", "城市", "医院", "疫苗", "规则", "秒杀时间", "秒杀编号")
 
 	// 生成城市编码文件
 	var allCityCodes map[string]interface{}
 	if !utils.FileExist(resource.CityCodeFile) {
-		allCityCodes, err = logic.GetYMEngine().FetchCityCode()
+		allCityCodes, err = logic.NewYMEngine(client).FetchCityCode(ctx)
 		if err != nil {
 			zap.L().Error("unable to get city code from api", zap.Error(err))
 			return err
@@ -125,81 +288,107 @@ func SniffService(configFile string) (err error) {
 		}
 	}
 
-	// 嗅探秒杀信息 - 约苗
-	ymResult, err := logic.GetYMEngine().Sniff()
-	if err != nil {
-		zap.L().Error("无法获取约苗当前哪些城市有秒杀信息", zap.Error(err))
-		return err
-	}
+	// 嗅探秒杀信息
+	reports := make([]*logic.SniffReport, 0, len(engines))
+	for _, registration := range engines {
+		engine := registration.New(client)
 
-	for _, v := range ymResult {
-		// 移除已过期的秒杀信息
-		if carbon.ParseByFormat(v["start_time"], carbon.DateTimeFormat).ToTimestamp() < carbon.Now().ToTimestamp() {
-			continue
+		// 记录本地时间与服务器时间的偏差，便于判断本地时间是否准确
+		logic.SyncEngineClock(ctx, engine, registration.Name)
+
+		report, err := engine.Sniff(ctx)
+		if err != nil && ctx.Err() == nil {
+			zap.L().Error(fmt.Sprintf("无法获取%s当前哪些城市有秒杀信息", registration.Name), zap.Error(err))
+			return err
 		}
+		reports = append(reports, report)
+		addSeckillRows(seckillTable, report.Results)
 
-		seckillRow := map[string]string{
-			"渠道":   v["source"],
-			"城市":   v["city"],
-			"医院":   v["hospital"],
-			"疫苗":   v["vaccine"],
-			"秒杀时间": v["start_time"],
-			"秒杀编号": v["seckill"],
+		// 上下文被取消或超时，输出已探测到的部分结果
+		if ctx.Err() != nil {
+			zap.L().Warn(fmt.Sprintf("%s的嗅探被中断，仅输出部分结果", registration.Name), zap.Error(ctx.Err()))
+			break
 		}
-		err = seckillTable.AddRow(seckillRow)
-		if err != nil {
-			zap.L().Error("add row to table failed", zap.Error(err))
-			continue
+	}
+
+	seckillTable.Print()
+
+	// 输出各渠道的嗅探情况
+	succeeded, failed := 0, 0
+	for _, report := range reports {
+		succeeded += report.Succeeded
+		failed += len(report.Errors)
+
+		fmt.Printf("%s: %d cities failed, %d hospitals failed, %d succeeded, took %s\n",
+			report.Source, report.FailedCities(), report.FailedHospitals(), report.Succeeded, report.Duration.Round(time.Millisecond))
+		for _, e := range report.Errors {
+			zap.L().Warn("嗅探失败", zap.String("source", string(report.Source)), zap.String("province", e.Province), zap.String("city", e.City), zap.String("hospital", e.Hospital), zap.Error(e.Err))
 		}
 	}
 
-	// 嗅探秒杀信息 - 知苗易约
-	zmyyResult, err := logic.GetZMYYEngine().Sniff()
-	if err != nil {
-		zap.L().Error("无法获取知苗易约当前哪些城市有秒杀信息", zap.Error(err))
-		return err
+	// 超时属于预期内的结束方式，被中断则返回错误
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
 	}
 
-	for _, v := range zmyyResult {
-		// 移除无法预约的秒杀信息
-		if v["start_time"] == "暂无" {
+	// 全部失败时无法区分是没有秒杀信息还是接口不可用，因此返回错误
+	if succeeded <= 0 && failed > 0 {
+		return fmt.Errorf("all %d lookups failed, please check the network or the API", failed)
+	}
+
+	return nil
+}
+
+// 将秒杀信息添加到表格中
+func addSeckillRows(seckillTable *models.Table, results []logic.SeckillInfo) {
+	now := time.Now()
+	for _, v := range results {
+		// 移除已过期的秒杀信息
+		if v.Expired(now) {
 			continue
-		} else {
-			dateSlice := strings.Split("12-03 17:05 至 12-03 17:10", " 至 ")
-			if len(dateSlice) == 2 {
-				v["start_time"] = fmt.Sprintf("%v-%s:00", carbon.Now().Year(), dateSlice[0])
-
-				// 移除已过期的秒杀信息
-				if carbon.ParseByFormat(v["start_time"], carbon.DateTimeFormat).ToTimestamp() < carbon.Now().ToTimestamp() {
-					continue
-				}
-			}
 		}
 
 		seckillRow := map[string]string{
-			"渠道":   v["source"],
-			"城市":   v["city"],
-			"医院":   v["hospital"],
-			"疫苗":   v["vaccine"],
-			"秒杀时间": v["start_time"],
-			"秒杀编号": v["seckill"],
+			"渠道":   v.Source.String(),
+			"城市":   v.City,
+			"医院":   v.HospitalName,
+			"疫苗":   v.VaccineName,
+			"规则":   v.Rule,
+			"秒杀时间": carbon.Time2Carbon(v.StartTime).ToDateTimeString(),
+			"秒杀编号": v.SeckillID,
 		}
-		err = seckillTable.AddRow(seckillRow)
-		if err != nil {
+		if err := seckillTable.AddRow(seckillRow); err != nil {
 			zap.L().Error("add row to table failed", zap.Error(err))
 			continue
 		}
 	}
-
-	seckillTable.Print()
-
-	return nil
 }
 
 // 秒杀疫苗
-func SeckillService(configFile string) (err error) {
-	// 解析配置文件
-	if err = configs.ParseConfigFile(configFile); err != nil {
+func SeckillService(ctx context.Context, options ServiceOptions) (err error) {
+	// 待秒杀的渠道，未指定时只通过约苗秒杀，避免未配置的渠道导致校验失败
+	sources := options.Sources
+	if len(sources) <= 0 {
+		sources = []string{string(logic.SourceYM)}
+	}
+	engines, err := logic.Select(sources, logic.CapabilitySeckill)
+	if err != nil {
+		return err
+	}
+
+	// 命令行参数的优先级最高
+	if err = configs.SetOverrides(options.Overrides); err != nil {
+		return err
+	}
+
+	configs.UseProfile(options.Profile)
+
+	// 解析配置文件，秒杀前需先嗅探，此外只校验待秒杀的渠道的配置
+	sections := []configs.Section{configs.SectionLogger, configs.SectionHTTP, configs.SectionSniff, configs.SectionSeckill}
+	for _, registration := range engines {
+		sections = append(sections, registration.Section)
+	}
+	if err = configs.ParseConfigFile(options.ConfigFile, sections...); err != nil {
 		return err
 	}
 
@@ -210,11 +399,38 @@ func SeckillService(configFile string) (err error) {
 	// 延迟注册：将缓存区的日志追加到日志文件中
 	defer logger.Sync()
 
-	// 秒杀疫苗 - 约苗
-	if err = logic.GetYMEngine().SecKill(); err != nil {
-		zap.L().Error("很抱歉，疫苗订购失败", zap.Error(err))
+	// 各渠道共享 HTTP 客户端的连接池
+	client, err := newHTTPClient(options)
+	if err != nil {
+		zap.L().Error("unable to create http client", zap.Error(err))
 		return err
 	}
 
+	// 各渠道的秒杀时间不同，因此同时秒杀
+	var wg sync.WaitGroup
+	errs := make([]error, len(engines))
+	for i, registration := range engines {
+		wg.Add(1)
+		go func(i int, registration logic.Registration) {
+			defer wg.Done()
+
+			if errs[i] = registration.New(client).SecKill(ctx); errs[i] != nil {
+				zap.L().Error(fmt.Sprintf("很抱歉，%s疫苗订购失败", registration.Name), zap.Error(errs[i]))
+			}
+		}(i, registration)
+	}
+	wg.Wait()
+
+	// 输出请求的耗时分布，便于判断慢在网络还是服务器
+	if client.Latency().Count() > 0 {
+		fmt.Print(client.Latency())
+	}
+
+	for i, e := range errs {
+		if e != nil {
+			return fmt.Errorf("%s: %w", engines[i].Name, e)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,156 @@
+package clock
+
+import (
+	"context"
+	"cupid/pkg/utils"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 默认的采样次数
+const DefaultSamples = 5
+
+// 采样间隔
+const sampleInterval = 100 * time.Millisecond
+
+// 时间源，返回服务器的当前时间和时间的精度
+type Source func(ctx context.Context) (serverTime time.Time, precision time.Duration, err error)
+
+// 一次采样的结果
+type Sample struct {
+	Offset time.Duration // 服务器时间与本地时间的偏差，为正时表示服务器时间更快
+	RTT    time.Duration // 往返时间
+}
+
+// 与服务器同步的时钟
+type Clock struct {
+	mutex  sync.RWMutex
+	offset time.Duration // 服务器时间与本地时间的偏差
+	rtt    time.Duration // 最佳采样的往返时间
+	synced time.Time     // 同步的本地时间
+}
+
+// 创建与本地时间一致的时钟，同步失败时可作为兜底
+func Local() *Clock {
+	return &Clock{synced: time.Now()}
+}
+
+// 与时间源同步，采样多次后选择往返时间最短的采样作为偏差的估计值
+//
+// 与 NTP 类似，假设请求和响应在网络上的耗时相同，则服务器时间对应本地的
+// 发送时间加上往返时间的一半，偏差 = 服务器时间 - (发送时间 + 往返时间 / 2)。
+func Sync(ctx context.Context, source Source, samples int) (*Clock, error) {
+	if samples <= 0 {
+		samples = DefaultSamples
+	}
+
+	var best *Sample
+	var lastErr error
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			if err := utils.Sleep(ctx, sampleInterval); err != nil {
+				return nil, err
+			}
+		}
+
+		sample, err := measure(ctx, source)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if best == nil || sample.RTT < best.RTT {
+			best = &sample
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("all %d samples failed: %w", samples, lastErr)
+	}
+
+	return &Clock{offset: best.Offset, rtt: best.RTT, synced: time.Now()}, nil
+}
+
+// 采样一次
+func measure(ctx context.Context, source Source) (Sample, error) {
+	sendTime := time.Now()
+	serverTime, precision, err := source(ctx)
+	if err != nil {
+		return Sample{}, err
+	}
+	rtt := time.Since(sendTime)
+
+	// 时间源的精度较低时，服务器时间被截断，取精度区间的中点
+	serverTime = serverTime.Add(precision / 2)
+
+	return Sample{
+		Offset: serverTime.Sub(sendTime.Add(rtt / 2)),
+		RTT:    rtt,
+	}, nil
+}
+
+// 获取服务器的当前时间
+func (clock *Clock) Now() time.Time {
+	clock.mutex.RLock()
+	defer clock.mutex.RUnlock()
+
+	return time.Now().Add(clock.offset)
+}
+
+// 获取服务器时间与本地时间的偏差
+func (clock *Clock) Offset() time.Duration {
+	clock.mutex.RLock()
+	defer clock.mutex.RUnlock()
+
+	return clock.offset
+}
+
+// 获取最佳采样的往返时间
+func (clock *Clock) RTT() time.Duration {
+	clock.mutex.RLock()
+	defer clock.mutex.RUnlock()
+
+	return clock.rtt
+}
+
+// 获取距离上次同步的时间
+func (clock *Clock) Age() time.Duration {
+	clock.mutex.RLock()
+	defer clock.mutex.RUnlock()
+
+	return time.Since(clock.synced)
+}
+
+// 重新与时间源同步，失败时保留原有的偏差
+func (clock *Clock) Resync(ctx context.Context, source Source, samples int) error {
+	synced, err := Sync(ctx, source, samples)
+	if err != nil {
+		return err
+	}
+
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.offset, clock.rtt, clock.synced = synced.offset, synced.rtt, synced.synced
+	return nil
+}
+
+// 休眠指定的时间，上下文被取消时提前返回
+func (clock *Clock) Sleep(ctx context.Context, duration time.Duration) error {
+	return utils.Sleep(ctx, duration)
+}
+
+// 从 HTTP 响应头中的 Date 获取服务器时间，精度为秒
+func HTTPDate(header http.Header) (time.Time, time.Duration, error) {
+	value := header.Get("Date")
+	if value == "" {
+		return time.Time{}, 0, fmt.Errorf("response has no Date header")
+	}
+
+	serverTime, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid Date header %q: %w", value, err)
+	}
+	return serverTime, time.Second, nil
+}
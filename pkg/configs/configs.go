@@ -1,9 +1,11 @@
 package configs
 
 import (
+	"fmt"
 	"io/fs"
-	"log"
-	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -12,34 +14,169 @@ import (
 	"github.com/spf13/viper"
 )
 
-// 全局配置的对象
-var AllConfig ServerConfig
+// 当前配置的快照，保存 *ServerConfig，配置文件更改时整体替换
+var current atomic.Value
+
+// 订阅者
+var (
+	subscribersMutex sync.Mutex
+	subscribers      = make(map[int]func(previous *ServerConfig, config *ServerConfig))
+	subscriberID     int
+)
+
+// 获取当前配置的快照，快照不可修改，配置文件更改后需重新获取
+func Get() *ServerConfig {
+	if config, ok := current.Load().(*ServerConfig); ok {
+		return config
+	}
+	return new(ServerConfig)
+}
+
+// 订阅配置的更改，配置文件更改且校验通过后在监视配置文件的协程中回调，返回取消订阅的函数
+func Subscribe(callback func(previous *ServerConfig, config *ServerConfig)) (cancel func()) {
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+
+	subscriberID++
+	id := subscriberID
+	subscribers[id] = callback
+
+	return func() {
+		subscribersMutex.Lock()
+		defer subscribersMutex.Unlock()
+
+		delete(subscribers, id)
+	}
+}
+
+// 替换当前配置，并通知订阅者
+func store(config *ServerConfig) {
+	previous := Get()
+	current.Store(config)
+
+	subscribersMutex.Lock()
+	callbacks := make([]func(previous *ServerConfig, config *ServerConfig), 0, len(subscribers))
+	for _, callback := range subscribers {
+		callbacks = append(callbacks, callback)
+	}
+	subscribersMutex.Unlock()
+
+	for _, callback := range callbacks {
+		callback(previous, config)
+	}
+}
 
 // 全局配置的结构体
 type ServerConfig struct {
-	Basic  BasicConfig  `mapstructure:"basic"`  // 基础配置
-	Logger LoggerConfig `mapstructure:"logger"` // 日志配置
-	Sniff  SniffConfig  `mapstructure:"sniff"`  // 嗅探
-	YM     YMConfig     `mapstructure:"ym"`     // 约苗
-	ZMYY   ZMYYConfig   `mapstructure:"zmyy"`   // 知苗易约
+	Basic   BasicConfig   `mapstructure:"basic"`   // 基础配置
+	Logger  LoggerConfig  `mapstructure:"logger"`  // 日志配置
+	HTTP    HTTPConfig    `mapstructure:"http"`    // HTTP 客户端
+	Sniff   SniffConfig   `mapstructure:"sniff"`   // 嗅探
+	Seckill SeckillConfig `mapstructure:"seckill"` // 秒杀
+	YM      YMConfig      `mapstructure:"ym"`      // 约苗
+	ZMYY    ZMYYConfig    `mapstructure:"zmyy"`    // 知苗易约
+
+	Profiles map[string]ProfileConfig `mapstructure:"profiles"` // 配置组，通过 --profile 选择
+	Profile  string                   `mapstructure:"-"`        // 选择的配置组，为空时使用顶层配置
+
+	mergeRegions bool // 是否合并所有配置组的区域
 }
 
 // 基础配置
 type BasicConfig struct {
-	Debug bool `mapstructure:"debug"` // 调试模式
+	Debug       bool   `mapstructure:"debug"`        // 调试模式
+	SecretsFile string `mapstructure:"secrets_file"` // 密钥文件，为空时使用默认路径
 }
 
 // 日志配置
 type LoggerConfig struct {
-	Level         string `mapstructure:"level"`          // 日志级别
+	Level         string `mapstructure:"level"`          // 日志级别，各输出未指定级别时使用
 	Directory     string `mapstructure:"directory"`      // 日志目录
 	RotationTime  int    `mapstructure:"rotation_time"`  // 日志轮换时间间隔，单位为小时
-	RotationCount uint   `mapstructure:"rotation_count"` // 日志轮换文件保留个数
+	RotationCount uint   `mapstructure:"rotation_count"` // 日志轮换文件保留个数，与 max_age 只能设置一个
+	MaxSize       int64  `mapstructure:"max_size"`       // 单个日志文件的大小上限，单位为 MB，超出后轮换，为 0 时不限制
+	MaxAge        int    `mapstructure:"max_age"`        // 日志文件保留的时间，单位为小时，与 rotation_count 只能设置一个
+	Compress      bool   `mapstructure:"compress"`       // 是否使用 gzip 压缩轮换出的日志文件
+	Redact        bool   `mapstructure:"redact"`         // 是否脱敏身份证号、手机号、Token 和 Cookie，默认启用，仅在本地调试时关闭
+
+	Console ConsoleSinkConfig `mapstructure:"console"` // 输出到标准错误
+	File    FileSinkConfig    `mapstructure:"file"`    // 输出到日志目录下轮换的 JSON 文件
+	Syslog  SyslogSinkConfig  `mapstructure:"syslog"`  // 输出到 syslog
+}
+
+// 标准错误的日志输出，使用便于阅读的格式
+type ConsoleSinkConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // 是否启用，默认启用
+	Level   string `mapstructure:"level"`   // 日志级别，为空时使用 logger.level
+	Color   bool   `mapstructure:"color"`   // 是否按日志级别着色，标准错误不是终端时不着色
+}
+
+// 日志文件的输出
+type FileSinkConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // 是否启用，默认启用
+	Level   string `mapstructure:"level"`   // 日志级别，为空时使用 logger.level
+}
+
+// syslog 的日志输出
+type SyslogSinkConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // 是否启用，默认不启用
+	Level   string `mapstructure:"level"`   // 日志级别，为空时使用 logger.level
+	Network string `mapstructure:"network"` // 网络类型，如 udp、tcp，为空时连接本机的 syslog 套接字
+	Address string `mapstructure:"address"` // 地址，如 localhost:514，network 为空时忽略
+	Tag     string `mapstructure:"tag"`     // 标签，为空时使用 cupid
+}
+
+// HTTP 客户端配置，未设置的字段使用默认值
+type HTTPConfig struct {
+	Timeout               time.Duration `mapstructure:"timeout"`                 // 单次请求的总超时时间
+	DialTimeout           time.Duration `mapstructure:"dial_timeout"`            // 建立连接的超时时间
+	TLSHandshakeTimeout   time.Duration `mapstructure:"tls_handshake_timeout"`   // TLS 握手的超时时间
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"` // 等待响应头的超时时间
+	IdleConnTimeout       time.Duration `mapstructure:"idle_conn_timeout"`       // 空闲连接保持的时间
+	MaxIdleConnsPerHost   int           `mapstructure:"max_idle_conns_per_host"` // 每个主机保持的空闲连接数
+	MaxResponseSize       int64         `mapstructure:"max_response_size"`       // 响应体的大小上限，单位为字节
 }
 
 // 嗅探配置
 type SniffConfig struct {
-	Regions []string `mapstructure:"regions"` // 区域，同时支持省粒度和市粒度
+	Regions     []string      `mapstructure:"regions"`       // 区域，支持省、市和区县粒度，以"!"开头表示排除，支持"*"通配符
+	Vaccines    []VaccineRule `mapstructure:"vaccines"`      // 疫苗的匹配规则，为空时只匹配九价疫苗
+	Workers     int           `mapstructure:"workers"`       // 并发嗅探的协程数
+	RatePerHost float64       `mapstructure:"rate_per_host"` // 每个主机每秒的请求数，小于 0 时不限流
+	Burst       int           `mapstructure:"burst"`         // 每个主机允许的突发请求数
+}
+
+// 疫苗的匹配规则
+type VaccineRule struct {
+	Name     string   `mapstructure:"name"`     // 规则名称
+	Keywords []string `mapstructure:"keywords"` // 关键字，疫苗名称包含任意一个关键字即匹配
+	Regex    string   `mapstructure:"regex"`    // 正则表达式
+	Excludes []string `mapstructure:"excludes"` // 排除的关键字
+}
+
+// 秒杀配置
+type SeckillConfig struct {
+	Timing TimingConfig `mapstructure:"timing"` // 秒杀的时间配置
+}
+
+// 秒杀的时间配置，未设置的字段使用默认值
+type TimingConfig struct {
+	Lead   time.Duration `mapstructure:"lead"`   // 在秒杀开始前提前发起请求的时间
+	Ladder []LadderStep  `mapstructure:"ladder"` // 轮询阶梯，距离秒杀开始越近，休息时间越短
+	Window WindowConfig  `mapstructure:"window"` // 发射窗口内发起请求的节奏
+	End    time.Duration `mapstructure:"end"`    // 秒杀开始后持续发起请求的时间
+}
+
+// 轮询阶梯的一级：距离秒杀开始的时间大于 Remaining 时，每次休息 Interval
+type LadderStep struct {
+	Remaining time.Duration `mapstructure:"remaining"` // 距离秒杀开始的时间
+	Interval  time.Duration `mapstructure:"interval"`  // 休息时间
+}
+
+// 发射窗口内发起请求的节奏
+type WindowConfig struct {
+	Interval time.Duration `mapstructure:"interval"` // 发起请求的间隔
+	Burst    int           `mapstructure:"burst"`    // 窗口打开时立即发起的请求数
 }
 
 // 约苗配置
@@ -52,46 +189,121 @@ type YMConfig struct {
 
 // 知苗易约配置
 type ZMYYConfig struct {
-	Cookie string `mapstructure:"cookie"` // Cookie
+	Cookie      string `mapstructure:"cookie"`       // Cookie，作为会话的初始 Cookie
+	SessionFile string `mapstructure:"session_file"` // 会话文件，保存服务器下发的最新 Cookie，为空时使用默认路径
+	SeckillID   string `mapstructure:"seckill_id"`   // 秒杀编号，即疫苗的产品编号
+	HospitalID  string `mapstructure:"hospital_id"`  // 医院编号，不同医院的产品编号相同时用于区分，可为空
+	Date        string `mapstructure:"date"`         // 预约日期，如 2021-12-10，为空时选择最早可预约的日期
 }
 
-func ParseConfigFile(configFile string) error {
-	// 指定配置文件路径
-	viper.SetConfigFile(configFile)
-	// 指定配置文件格式
-	viper.SetConfigType("yaml")
-
-	// 解析配置文件
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Fatalf("the configuration file does not exist, err: %s\n", err.Error())
-		} else if _, flag := err.(*fs.PathError); flag {
-			log.Fatalf("the configuration dir does not exist, err: %s\n", err.Error())
-		} else {
-			log.Fatalf("could not load configuration file, err: %s\n", err.Error())
-		}
+// 解析并校验配置文件中的指定分区，配置文件发生更改时重新加载
+//
+// 配置项的优先级：命令行参数 --set > 环境变量 CUPID_* > 配置文件 > 默认值。
+//
+// 更改后的配置文件无法解析或校验失败时，保留上一次有效的配置。
+func ParseConfigFile(configFile string, sections ...Section) error {
+	if err := readConfig(configFile); err != nil {
 		return err
 	}
 
-	// 反序列化
-	if err := viper.Unmarshal(&AllConfig); err != nil {
-		log.Fatalf("Unable to unmarshal config, err: %s\n", err.Error())
+	config, err := unmarshal(sections)
+	if err != nil {
 		return err
 	}
+	store(config)
 
 	// 动态加载配置文件：监视配置文件
 	viper.WatchConfig()
 	// 配置文件发生更改
 	viper.OnConfigChange(func(event fsnotify.Event) {
-		if event.Op == fsnotify.Write {
-			// 反序列化
-			if err := viper.Unmarshal(&AllConfig); err != nil {
-				zap.L().Error("unable to unmarshal config", zap.Error(err))
-				os.Exit(1)
-			}
-			zap.L().Info("the configuration file has changed")
+		// 编辑器保存或 mv 替换配置文件时为 Create 事件
+		if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+			return
 		}
+
+		// viper 读取失败时只输出到标准库的日志，并保留上一次的内容，因此重新读取以获取错误
+		if err := viper.ReadInConfig(); err != nil {
+			zap.L().Error("the configuration file has changed but cannot be parsed, keep the previous one", zap.Error(err))
+			return
+		}
+
+		config, err := unmarshal(sections)
+		if err != nil {
+			zap.L().Error("the configuration file has changed but is invalid, keep the previous one", zap.Error(err))
+			return
+		}
+		store(config)
+		zap.L().Info("the configuration file has changed")
 	})
 
 	return nil
 }
+
+// 加载生效的配置，应用环境变量、--set 和配置组，但不解析密钥引用、不校验、不监视配置文件
+func Load(configFile string) (*ServerConfig, error) {
+	if err := readConfig(configFile); err != nil {
+		return nil, err
+	}
+
+	config := new(ServerConfig)
+	if err := viper.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal config: %w", err)
+	}
+	if err := applyProfile(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// 设置默认值，零值不适用的配置项需要在此设置
+func setDefaults() {
+	viper.SetDefault("logger.redact", true)
+	viper.SetDefault("logger.console.enabled", true)
+	viper.SetDefault("logger.console.color", true)
+	viper.SetDefault("logger.file.enabled", true)
+}
+
+// 读取配置文件
+func readConfig(configFile string) error {
+	// 指定配置文件路径
+	viper.SetConfigFile(configFile)
+	// 指定配置文件格式
+	viper.SetConfigType("yaml")
+
+	// 配置文件中未设置的配置项使用默认值
+	setDefaults()
+
+	// 环境变量的优先级高于配置文件
+	if err := bindEnv(); err != nil {
+		return err
+	}
+
+	// 解析配置文件
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return fmt.Errorf("the configuration file does not exist: %w", err)
+		} else if _, flag := err.(*fs.PathError); flag {
+			return fmt.Errorf("the configuration dir does not exist: %w", err)
+		}
+		return fmt.Errorf("could not load configuration file: %w", err)
+	}
+	return nil
+}
+
+// 反序列化并校验配置
+func unmarshal(sections []Section) (*ServerConfig, error) {
+	config := new(ServerConfig)
+	if err := viper.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal config: %w", err)
+	}
+	if err := applyProfile(config); err != nil {
+		return nil, err
+	}
+	if err := resolveSecrets(config, sections); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(sections...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,118 @@
+package configs
+
+import (
+	"cupid/pkg/secrets"
+	"cupid/pkg/sensitive"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 将配置输出为 YAML，mask 为 true 时隐藏 Token、Cookie、接种人等敏感字段，secret:// 引用原样输出
+//
+// 输出的 YAML 可被 ParseConfigFile 解析，字段顺序与结构体的定义一致。
+func Encode(w io.Writer, config *ServerConfig, mask bool) error {
+	encoder := &yamlEncoder{mask: mask}
+	encoder.encodeStruct(reflect.ValueOf(*config), 0, true)
+	_, err := io.WriteString(w, encoder.builder.String())
+	return err
+}
+
+// 简单的 YAML 编码器，只支持配置中使用的类型
+type yamlEncoder struct {
+	builder strings.Builder
+	mask    bool
+}
+
+// 编码结构体，顶层的分区之间以空行分隔
+func (encoder *yamlEncoder) encodeStruct(value reflect.Value, indent int, topLevel bool) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		if topLevel && value.Field(i).Kind() == reflect.Map && value.Field(i).Len() <= 0 {
+			continue
+		}
+		if topLevel && i > 0 {
+			encoder.builder.WriteString("\n")
+		}
+		encoder.encodeField(name, value.Field(i), indent, sensitive.Key(name))
+	}
+}
+
+// 编码字段
+func (encoder *yamlEncoder) encodeField(name string, value reflect.Value, indent int, secret bool) {
+	prefix := strings.Repeat("  ", indent)
+
+	switch {
+	case value.Type() == reflect.TypeOf(time.Duration(0)):
+		fmt.Fprintf(&encoder.builder, "%s%s: %s\n", prefix, name, strconv.Quote(value.Interface().(time.Duration).String()))
+	case value.Kind() == reflect.Struct:
+		fmt.Fprintf(&encoder.builder, "%s%s:\n", prefix, name)
+		encoder.encodeStruct(value, indent+1, false)
+	case value.Kind() == reflect.Map:
+		fmt.Fprintf(&encoder.builder, "%s%s:", prefix, name)
+		if value.Len() <= 0 {
+			encoder.builder.WriteString(" {}\n")
+			return
+		}
+		encoder.builder.WriteString("\n")
+		keys := make([]string, 0, value.Len())
+		for _, key := range value.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			encoder.encodeField(key, value.MapIndex(reflect.ValueOf(key)), indent+1, false)
+		}
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct:
+		fmt.Fprintf(&encoder.builder, "%s%s:", prefix, name)
+		if value.Len() <= 0 {
+			encoder.builder.WriteString(" []\n")
+			return
+		}
+		encoder.builder.WriteString("\n")
+		for i := 0; i < value.Len(); i++ {
+			// 列表元素的第一个字段与"-"同行
+			item := &yamlEncoder{mask: encoder.mask}
+			item.encodeStruct(value.Index(i), 0, false)
+			for j, line := range strings.Split(strings.TrimRight(item.builder.String(), "\n"), "\n") {
+				marker := "  "
+				if j == 0 {
+					marker = "- "
+				}
+				fmt.Fprintf(&encoder.builder, "%s  %s%s\n", prefix, marker, line)
+			}
+		}
+	case value.Kind() == reflect.Slice:
+		items := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, encoder.scalar(value.Index(i), secret))
+		}
+		fmt.Fprintf(&encoder.builder, "%s%s: [%s]\n", prefix, name, strings.Join(items, ", "))
+	default:
+		fmt.Fprintf(&encoder.builder, "%s%s: %s\n", prefix, name, encoder.scalar(value, secret))
+	}
+}
+
+// 编码标量
+func (encoder *yamlEncoder) scalar(value reflect.Value, secret bool) string {
+	switch value.Kind() {
+	case reflect.String:
+		s := value.String()
+		if secret && encoder.mask && s != "" && !strings.HasPrefix(s, secrets.Scheme) {
+			s = sensitive.Mask
+		}
+		return strconv.Quote(s)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'g', -1, 64)
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
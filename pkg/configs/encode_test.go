@@ -0,0 +1,59 @@
+package configs
+
+import (
+	"cupid/pkg/sensitive"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMask(t *testing.T) {
+	config := &ServerConfig{
+		YM:   YMConfig{Token: "wxapptoken", SeckillID: "1276", LinkmanID: "18552351", LinkmanIDCard: "secret://ym.linkman_id_card"},
+		ZMYY: ZMYYConfig{Cookie: "ASP.NET_SessionId=abc", SeckillID: "1"},
+		Profiles: map[string]ProfileConfig{
+			"alice": {YM: YMConfig{Token: "alicetoken", LinkmanID: "20001"}},
+		},
+	}
+
+	cases := []struct {
+		mask    bool
+		want    []string
+		notWant []string
+	}{
+		{
+			mask: true,
+			want: []string{
+				"token: " + strconv.Quote(sensitive.Mask),
+				"linkman_id: " + strconv.Quote(sensitive.Mask),
+				"cookie: " + strconv.Quote(sensitive.Mask),
+				`linkman_id_card: "secret://ym.linkman_id_card"`,
+				`seckill_id: "1276"`,
+			},
+			notWant: []string{"wxapptoken", "18552351", "ASP.NET_SessionId", "alicetoken", "20001"},
+		},
+		{
+			mask: false,
+			want: []string{`token: "wxapptoken"`, `linkman_id: "18552351"`, `token: "alicetoken"`},
+		},
+	}
+
+	for _, c := range cases {
+		var builder strings.Builder
+		if err := Encode(&builder, config, c.mask); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+
+		output := builder.String()
+		for _, s := range c.want {
+			if !strings.Contains(output, s) {
+				t.Errorf("Encode(mask=%v) = %s, want it to contain %s", c.mask, output, s)
+			}
+		}
+		for _, s := range c.notWant {
+			if strings.Contains(output, s) {
+				t.Errorf("Encode(mask=%v) = %s, want it not to contain %s", c.mask, output, s)
+			}
+		}
+	}
+}
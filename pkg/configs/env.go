@@ -0,0 +1,122 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// 环境变量的前缀，如 ym.token 对应 CUPID_YM_TOKEN
+const EnvPrefix = "CUPID"
+
+// 绑定所有配置项的环境变量，优先级高于配置文件
+func bindEnv() error {
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	for _, key := range Keys() {
+		if err := viper.BindEnv(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 环境变量的名称
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// 通过命令行参数覆盖的配置项
+var (
+	overridesMutex sync.Mutex
+	overrideKeys   = make(map[string]bool)
+)
+
+// 判断配置项是否被命令行参数或环境变量覆盖
+func overridden(key string) bool {
+	overridesMutex.Lock()
+	defer overridesMutex.Unlock()
+
+	if overrideKeys[key] {
+		return true
+	}
+	_, ok := os.LookupEnv(EnvName(key))
+	return ok
+}
+
+// 使用命令行参数覆盖配置项，格式为 key=value，如 ym.token=xxx，优先级高于环境变量和配置文件
+//
+// 列表以逗号分隔，如 sniff.regions=四川省,!四川省-成都市。
+func SetOverrides(overrides []string) error {
+	known := make(map[string]bool)
+	for _, key := range Keys() {
+		known[key] = true
+	}
+
+	problems := make([]Problem, 0)
+	for _, override := range overrides {
+		index := strings.Index(override, "=")
+		if index <= 0 {
+			problems = append(problems, Problem{Path: override, Message: "must be in the form key=value"})
+			continue
+		}
+
+		key, value := strings.TrimSpace(override[:index]), override[index+1:]
+		if !known[key] {
+			problems = append(problems, Problem{Path: key, Message: "unknown configuration key"})
+			continue
+		}
+		viper.Set(key, value)
+
+		overridesMutex.Lock()
+		overrideKeys[key] = true
+		overridesMutex.Unlock()
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// 所有可被环境变量和命令行参数覆盖的配置项，如 ym.token
+//
+// 元素为结构体的列表（如 sniff.vaccines）和配置组只能在配置文件中设置，因此不包括在内。
+func Keys() []string {
+	keys := make([]string, 0)
+	collectKeys(reflect.TypeOf(ServerConfig{}), "", &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+// 递归收集结构体中的配置项
+func collectKeys(t reflect.Type, prefix string, keys *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = fmt.Sprintf("%s.%s", prefix, name)
+		}
+
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			collectKeys(field.Type, key, keys)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			continue
+		case field.Type.Kind() == reflect.Map:
+			continue
+		default:
+			*keys = append(*keys, key)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package configs
+
+import (
+	"cupid/resource"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 配置组：每个人各自的凭证、接种人、秒杀目标和区域，其余配置与顶层共享
+type ProfileConfig struct {
+	Regions []string   `mapstructure:"regions"` // 嗅探的区域，覆盖 sniff.regions
+	YM      YMConfig   `mapstructure:"ym"`      // 约苗，非空的字段覆盖顶层的 ym
+	ZMYY    ZMYYConfig `mapstructure:"zmyy"`    // 知苗易约，非空的字段覆盖顶层的 zmyy
+}
+
+// 配置组的选择
+var (
+	profileMutex        sync.Mutex
+	profileName         string // 选择的配置组，为空时使用顶层配置
+	mergeProfileRegions bool   // 未选择配置组时，是否合并所有配置组的区域
+)
+
+// 选择配置组，需在解析配置文件前调用，为空时使用顶层配置
+func UseProfile(name string) {
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+
+	profileName = name
+}
+
+// 未选择配置组时合并所有配置组的区域，用于一次嗅探所有人关注的区域
+func MergeProfileRegions(enabled bool) {
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+
+	mergeProfileRegions = enabled
+}
+
+// 所有配置组的名称
+func (config *ServerConfig) ProfileNames() []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 区域规则的分组，每组单独选择区域
+type RegionGroup struct {
+	Profile string   // 配置组的名称，顶层配置为空
+	Regions []string // 区域规则
+}
+
+// 待嗅探的区域规则
+//
+// 合并所有配置组的区域时，顶层的 sniff.regions 和每个配置组的 regions 各为一组，逐组选择区域后取并集，
+// 避免一个配置组的排除规则影响其他配置组，顶层为空时仍表示所有区域；否则只有生效的 sniff.regions。
+func (config *ServerConfig) RegionGroups() []RegionGroup {
+	groups := []RegionGroup{{Regions: config.Sniff.Regions}}
+	if !config.mergeRegions {
+		return groups
+	}
+
+	for _, name := range config.ProfileNames() {
+		// 未指定区域的配置组使用顶层的区域，已包含在第一组中
+		if regions := config.Profiles[name].Regions; len(regions) > 0 {
+			groups = append(groups, RegionGroup{Profile: name, Regions: regions})
+		}
+	}
+	return groups
+}
+
+// 将选择的配置组合并到顶层配置中
+func applyProfile(config *ServerConfig) error {
+	profileMutex.Lock()
+	name, merge := profileName, mergeProfileRegions
+	profileMutex.Unlock()
+
+	if name == "" {
+		// 命令行参数或环境变量指定的区域优先于配置组
+		config.mergeRegions = merge && !overridden("sniff.regions")
+		return nil
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return &ValidationError{Problems: []Problem{{
+			Path:    "profiles",
+			Message: fmt.Sprintf("unknown profile %q, available: [%s]", name, strings.Join(config.ProfileNames(), ", ")),
+		}}}
+	}
+
+	// 配置组的优先级低于命令行参数和环境变量
+	config.Profile = name
+	if len(profile.Regions) > 0 && !overridden("sniff.regions") {
+		config.Sniff.Regions = profile.Regions
+	}
+	overlay(reflect.ValueOf(&config.YM).Elem(), reflect.ValueOf(profile.YM), "ym")
+	overlay(reflect.ValueOf(&config.ZMYY).Elem(), reflect.ValueOf(profile.ZMYY), "zmyy")
+
+	// 每个配置组的 Cookie 不同，未单独指定会话文件时按配置组区分
+	if profile.ZMYY.SessionFile == "" {
+		config.ZMYY.SessionFile = profileFile(config.ZMYY.SessionFile, resource.ZMYYSessionFile, name)
+	}
+	return nil
+}
+
+// 使用 src 中非零值的字段覆盖 dst，跳过被命令行参数或环境变量覆盖的配置项
+func overlay(dst reflect.Value, src reflect.Value, prefix string) {
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Field(i)
+		key := prefix + "." + src.Type().Field(i).Tag.Get("mapstructure")
+		if field.Kind() == reflect.Struct {
+			overlay(dst.Field(i), field, key)
+			continue
+		}
+		if !field.IsZero() && !overridden(key) {
+			dst.Field(i).Set(field)
+		}
+	}
+}
+
+// 在文件名的扩展名前加上配置组的名称，如 ./sessions/zmyy.json -> ./sessions/zmyy-alice.json
+func profileFile(path string, defaultPath string, name string) string {
+	if path == "" {
+		path = defaultPath
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), name, ext)
+}
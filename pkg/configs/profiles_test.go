@@ -0,0 +1,76 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegionGroups(t *testing.T) {
+	newConfig := func(regions ...string) *ServerConfig {
+		return &ServerConfig{
+			Sniff: SniffConfig{Regions: regions},
+			Profiles: map[string]ProfileConfig{
+				"alice": {Regions: []string{"四川省", "!四川省-成都市"}},
+				"bob":   {Regions: []string{"四川省-成都市"}},
+				"carol": {},
+			},
+		}
+	}
+
+	cases := []struct {
+		name    string
+		profile string
+		merge   bool
+		regions []string
+		want    []RegionGroup
+	}{
+		{
+			name:  "merge keeps each profile apart",
+			merge: true,
+			want: []RegionGroup{
+				{Regions: nil},
+				{Profile: "alice", Regions: []string{"四川省", "!四川省-成都市"}},
+				{Profile: "bob", Regions: []string{"四川省-成都市"}},
+			},
+		},
+		{
+			name:    "merge with top-level regions",
+			merge:   true,
+			regions: []string{"直辖市-重庆市"},
+			want: []RegionGroup{
+				{Regions: []string{"直辖市-重庆市"}},
+				{Profile: "alice", Regions: []string{"四川省", "!四川省-成都市"}},
+				{Profile: "bob", Regions: []string{"四川省-成都市"}},
+			},
+		},
+		{
+			name:    "without merge",
+			regions: []string{"直辖市-重庆市"},
+			want:    []RegionGroup{{Regions: []string{"直辖市-重庆市"}}},
+		},
+		{
+			name:    "selected profile",
+			profile: "bob",
+			merge:   true,
+			regions: []string{"直辖市-重庆市"},
+			want:    []RegionGroup{{Regions: []string{"四川省-成都市"}}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			UseProfile(c.profile)
+			MergeProfileRegions(c.merge)
+			defer UseProfile("")
+			defer MergeProfileRegions(false)
+
+			config := newConfig(c.regions...)
+			if err := applyProfile(config); err != nil {
+				t.Fatalf("applyProfile() error = %v", err)
+			}
+			if got := config.RegionGroups(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("RegionGroups() = %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
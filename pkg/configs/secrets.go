@@ -0,0 +1,103 @@
+package configs
+
+import (
+	"cupid/pkg/secrets"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// 获取解锁密钥文件的凭证，默认从环境变量或终端读取
+var SecretCredential = func() (secrets.Credential, error) {
+	return secrets.CredentialFromEnv(os.Stderr, os.Stdin)
+}
+
+// 已解锁的密钥文件及解锁失败的原因，重新加载配置时复用，避免重复输入口令
+var (
+	secretStoresMutex sync.Mutex
+	secretStores      = make(map[string]*secrets.Store)
+	secretErrors      = make(map[string]error)
+)
+
+// 密钥文件的路径
+func (config *ServerConfig) SecretsFile() string {
+	if config.Basic.SecretsFile != "" {
+		return config.Basic.SecretsFile
+	}
+	return secrets.DefaultFile
+}
+
+// 将指定分区中 secret:// 开头的配置项替换为密钥文件中的值，未指定分区时替换所有分区
+func resolveSecrets(config *ServerConfig, sections []Section) error {
+	wanted := make(map[string]bool)
+	for _, section := range sections {
+		wanted[string(section)] = true
+	}
+
+	problems := make([]Problem, 0)
+	value := reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		name := value.Type().Field(i).Tag.Get("mapstructure")
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		resolveValue(config, value.Field(i), name, &problems)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// 递归替换结构体中的字符串字段
+func resolveValue(config *ServerConfig, value reflect.Value, path string, problems *[]Problem) {
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			name := value.Type().Field(i).Tag.Get("mapstructure")
+			if name == "" {
+				continue
+			}
+			resolveValue(config, value.Field(i), path+"."+name, problems)
+		}
+	case reflect.String:
+		reference := value.String()
+		if !strings.HasPrefix(reference, secrets.Scheme) {
+			return
+		}
+
+		secret, err := lookupSecret(config.SecretsFile(), strings.TrimPrefix(reference, secrets.Scheme))
+		if err != nil {
+			*problems = append(*problems, Problem{Path: path, Message: fmt.Sprintf("unable to resolve %s: %s", reference, err)})
+			return
+		}
+		value.SetString(secret)
+	}
+}
+
+// 从密钥文件中获取密钥，首次使用时解锁密钥文件
+func lookupSecret(file string, name string) (string, error) {
+	secretStoresMutex.Lock()
+	defer secretStoresMutex.Unlock()
+
+	if err, ok := secretErrors[file]; ok {
+		return "", err
+	}
+
+	store, ok := secretStores[file]
+	if !ok {
+		credential, err := SecretCredential()
+		if err == nil {
+			store, err = secrets.Open(file, credential)
+		}
+		if err != nil {
+			secretErrors[file] = err
+			return "", err
+		}
+		secretStores[file] = store
+	}
+	return store.Get(name)
+}
@@ -0,0 +1,287 @@
+package configs
+
+import (
+	"cupid/pkg/secrets"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 配置的分区，各命令只校验需要的分区
+type Section string
+
+const (
+	SectionLogger  Section = "logger"
+	SectionHTTP    Section = "http"
+	SectionSniff   Section = "sniff"
+	SectionSeckill Section = "seckill"
+	SectionYM      Section = "ym"
+	SectionZMYY    Section = "zmyy"
+)
+
+// 所有分区
+var AllSections = []Section{SectionLogger, SectionHTTP, SectionSniff, SectionSeckill, SectionYM, SectionZMYY}
+
+// 配置中的一个问题
+type Problem struct {
+	Path    string // YAML 路径，如 ym.token
+	Message string // 问题描述
+}
+
+func (problem Problem) String() string {
+	return fmt.Sprintf("%s: %s", problem.Path, problem.Message)
+}
+
+// 配置校验失败，包含所有的问题
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		lines = append(lines, problem.String())
+	}
+	return fmt.Sprintf("invalid configuration, %d problem(s):\n  %s", len(e.Problems), strings.Join(lines, "\n  "))
+}
+
+// 校验器，收集所有的问题
+type validator struct {
+	problems []Problem
+}
+
+// 记录问题
+func (v *validator) add(path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// 身份证号：17 位数字加 1 位数字或 X
+var idCardPattern = regexp.MustCompile(`^\d{17}[\dXx]$`)
+
+// 校验指定的分区，未指定时校验所有分区，返回包含所有问题的 *ValidationError
+func (config *ServerConfig) Validate(sections ...Section) error {
+	if len(sections) <= 0 {
+		sections = AllSections
+	}
+
+	v := new(validator)
+	checked := make(map[Section]bool)
+	for _, section := range sections {
+		if checked[section] {
+			continue
+		}
+		checked[section] = true
+
+		switch section {
+		case SectionLogger:
+			config.Logger.validate(v)
+		case SectionHTTP:
+			config.HTTP.validate(v)
+		case SectionSniff:
+			config.Sniff.validate(v)
+		case SectionSeckill:
+			config.Seckill.validate(v)
+		case SectionYM:
+			config.YM.validate(v)
+		case SectionZMYY:
+			config.ZMYY.validate(v)
+		default:
+			v.add(string(section), "unknown section")
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (config LoggerConfig) validate(v *validator) {
+	validateLevel(v, "logger.level", config.Level, false)
+	validateLevel(v, "logger.console.level", config.Console.Level, true)
+	validateLevel(v, "logger.file.level", config.File.Level, true)
+	validateLevel(v, "logger.syslog.level", config.Syslog.Level, true)
+
+	if !config.Console.Enabled && !config.File.Enabled && !config.Syslog.Enabled {
+		v.add("logger", "at least one of console, file and syslog must be enabled")
+	}
+
+	if config.File.Enabled {
+		if config.Directory == "" {
+			v.add("logger.directory", "required")
+		}
+		if config.RotationTime <= 0 {
+			v.add("logger.rotation_time", "must be a positive number of hours, got %d", config.RotationTime)
+		}
+		if config.MaxSize < 0 {
+			v.add("logger.max_size", "must not be negative, got %d", config.MaxSize)
+		}
+		if config.MaxAge < 0 {
+			v.add("logger.max_age", "must not be negative, got %d", config.MaxAge)
+		}
+		if config.RotationCount > 0 && config.MaxAge > 0 {
+			v.add("logger.max_age", "cannot be set together with logger.rotation_count")
+		} else if config.RotationCount <= 0 && config.MaxAge <= 0 {
+			v.add("logger.rotation_count", "either rotation_count or max_age must be positive")
+		}
+	}
+
+	if config.Syslog.Enabled {
+		switch config.Syslog.Network {
+		case "":
+		case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix", "unixgram":
+			if config.Syslog.Address == "" {
+				v.add("logger.syslog.address", "required when network is %s", config.Syslog.Network)
+			}
+		default:
+			v.add("logger.syslog.network", "unknown network %q, expected one of tcp, udp, unix, unixgram", config.Syslog.Network)
+		}
+	}
+}
+
+// 校验日志级别，optional 为 true 时允许为空
+func validateLevel(v *validator, path string, value string, optional bool) {
+	if value == "" && optional {
+		return
+	}
+	level := new(zapcore.Level)
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		v.add(path, "unknown level %q, expected one of debug, info, warn, error", value)
+	}
+}
+
+func (config HTTPConfig) validate(v *validator) {
+	durations := map[string]time.Duration{
+		"http.timeout":                 config.Timeout,
+		"http.dial_timeout":            config.DialTimeout,
+		"http.tls_handshake_timeout":   config.TLSHandshakeTimeout,
+		"http.response_header_timeout": config.ResponseHeaderTimeout,
+		"http.idle_conn_timeout":       config.IdleConnTimeout,
+	}
+	for _, path := range sortedKeys(durations) {
+		if durations[path] < 0 {
+			v.add(path, "must not be negative, got %s", durations[path])
+		}
+	}
+	if config.MaxIdleConnsPerHost < 0 {
+		v.add("http.max_idle_conns_per_host", "must not be negative, got %d", config.MaxIdleConnsPerHost)
+	}
+	if config.MaxResponseSize < 0 {
+		v.add("http.max_response_size", "must not be negative, got %d", config.MaxResponseSize)
+	}
+}
+
+func (config SniffConfig) validate(v *validator) {
+	// 区域为空或只有排除规则时嗅探所有区域，因此只校验每个区域非空
+	for i, pattern := range config.Regions {
+		if strings.TrimSpace(strings.TrimPrefix(pattern, "!")) == "" {
+			v.add(fmt.Sprintf("sniff.regions[%d]", i), "must not be empty")
+		}
+	}
+
+	for i, rule := range config.Vaccines {
+		path := fmt.Sprintf("sniff.vaccines[%d]", i)
+		if rule.Name == "" {
+			v.add(path+".name", "required")
+		}
+		if len(rule.Keywords) <= 0 && rule.Regex == "" {
+			v.add(path, "either keywords or regex is required")
+		}
+		if rule.Regex != "" {
+			if _, err := regexp.Compile(rule.Regex); err != nil {
+				v.add(path+".regex", "invalid regular expression: %s", err)
+			}
+		}
+	}
+
+	if config.Workers < 0 {
+		v.add("sniff.workers", "must not be negative, got %d", config.Workers)
+	}
+	if config.Burst < 0 {
+		v.add("sniff.burst", "must not be negative, got %d", config.Burst)
+	}
+}
+
+func (config SeckillConfig) validate(v *validator) {
+	timing := config.Timing
+	if timing.Lead < 0 {
+		v.add("seckill.timing.lead", "must not be negative, got %s", timing.Lead)
+	}
+	for i, step := range timing.Ladder {
+		path := fmt.Sprintf("seckill.timing.ladder[%d]", i)
+		if step.Remaining < 0 {
+			v.add(path+".remaining", "must not be negative, got %s", step.Remaining)
+		}
+		if step.Interval <= 0 {
+			v.add(path+".interval", "must be positive, got %s", step.Interval)
+		}
+	}
+	if timing.Window.Interval < 0 {
+		v.add("seckill.timing.window.interval", "must not be negative, got %s", timing.Window.Interval)
+	}
+	if timing.Window.Burst < 0 {
+		v.add("seckill.timing.window.burst", "must not be negative, got %d", timing.Window.Burst)
+	}
+	if timing.End < 0 {
+		v.add("seckill.timing.end", "must not be negative, got %s", timing.End)
+	}
+}
+
+func (config YMConfig) validate(v *validator) {
+	if config.Token == "" {
+		v.add("ym.token", "required for seckill")
+	}
+	requireNumber(v, "ym.seckill_id", config.SeckillID, true)
+	requireNumber(v, "ym.linkman_id", config.LinkmanID, true)
+	if config.LinkmanIDCard == "" {
+		v.add("ym.linkman_id_card", "required for seckill")
+	} else if !isSecretRef(config.LinkmanIDCard) && !idCardPattern.MatchString(config.LinkmanIDCard) {
+		v.add("ym.linkman_id_card", "must be an 18-character ID card number")
+	}
+}
+
+func (config ZMYYConfig) validate(v *validator) {
+	requireNumber(v, "zmyy.seckill_id", config.SeckillID, true)
+	requireNumber(v, "zmyy.hospital_id", config.HospitalID, false)
+	if config.Date != "" {
+		if _, err := time.Parse("2006-01-02", config.Date); err != nil {
+			v.add("zmyy.date", "must be a date like 2021-12-10, got %q", config.Date)
+		}
+	}
+}
+
+// 校验数字编号，required 为 true 时不能为空
+func requireNumber(v *validator, path string, value string, required bool) {
+	if value == "" {
+		if required {
+			v.add(path, "required for seckill")
+		}
+		return
+	}
+	if isSecretRef(value) {
+		return
+	}
+	if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+		v.add(path, "must be numeric, got %q", value)
+	}
+}
+
+// 未解析的密钥引用只在生成配置文件时出现，运行时已替换为密钥的值，不校验格式
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, secrets.Scheme)
+}
+
+// 按字母顺序返回键，使问题的顺序稳定
+func sortedKeys(values map[string]time.Duration) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,7 +3,6 @@ package logger
 import (
 	"cupid/pkg/configs"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 	"time"
@@ -15,75 +14,160 @@ import (
 
 var Logger *zap.Logger
 
-// 初始化日志对象
+// 初始化日志对象，按配置输出到标准错误、日志文件和 syslog
 func Init(loggerName string) error {
-	// 获取日志编码器
-	encoder := getEncoder()
+	config := configs.Get().Logger
+
+	cores := make([]zapcore.Core, 0, 3)
+	if config.Console.Enabled {
+		core, err := newConsoleCore(config)
+		if err != nil {
+			return err
+		}
+		cores = append(cores, core)
+	}
+	if config.File.Enabled {
+		core, err := newFileCore(config, loggerName)
+		if err != nil {
+			return err
+		}
+		cores = append(cores, core)
+	}
+	if config.Syslog.Enabled {
+		core, err := newSyslogCore(config)
+		if err != nil {
+			return err
+		}
+		cores = append(cores, core)
+	}
+
+	// 添加调用者信息和行数
+	Logger = zap.New(zapcore.NewTee(cores...), zap.AddCaller())
+
+	// 全局替换Zap的日志对象
+	zap.ReplaceGlobals(Logger)
+
+	if !config.Redact {
+		zap.L().Warn("日志脱敏已关闭，日志中可能包含身份证号、手机号、Token 和 Cookie")
+	}
+
+	return nil
+}
 
-	// 根据配置文件设置日志级别
-	level := new(zapcore.Level)
-	if err := level.UnmarshalText([]byte(configs.AllConfig.Logger.Level)); err != nil {
-		return err
+// 获取输出的日志级别，未指定时使用 logger.level
+func getLevel(config configs.LoggerConfig, level string) (zapcore.Level, error) {
+	if level == "" {
+		level = config.Level
+	}
+
+	result := new(zapcore.Level)
+	if err := result.UnmarshalText([]byte(level)); err != nil {
+		return zap.InfoLevel, err
+	}
+	return *result, nil
+}
+
+// 创建标准错误的日志核心
+func newConsoleCore(config configs.LoggerConfig) (zapcore.Core, error) {
+	level, err := getLevel(config, config.Console.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	encoderConfig := newEncoderConfig()
+	// 标准错误是终端时按日志级别着色
+	if config.Console.Color && isTerminal(os.Stderr) {
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+
+	encoder := redact(zapcore.NewConsoleEncoder(encoderConfig), config.Redact)
+	return zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), level), nil
+}
+
+// 创建日志文件的日志核心
+func newFileCore(config configs.LoggerConfig, loggerName string) (zapcore.Core, error) {
+	level, err := getLevel(config, config.File.Level)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建日志目录
-	if err := os.MkdirAll(configs.AllConfig.Logger.Directory, 0755); err != nil {
-		return err
+	if err := os.MkdirAll(config.Directory, 0755); err != nil {
+		return nil, err
 	}
 
-	// 设置日志核心
-	var core zapcore.Core
-	if *level == zap.InfoLevel {
-		// 获取不同日志级别的输出流
-		infoWriteSyncer := getWriteSyncer(fmt.Sprintf("%s/%s-info.log", configs.AllConfig.Logger.Directory, loggerName))
-		errorWriteSyncer := getWriteSyncer(fmt.Sprintf("%s/%s-error.log", configs.AllConfig.Logger.Directory, loggerName))
+	// 获取日志编码器
+	encoder := redact(getEncoder(), config.Redact)
 
-		core = zapcore.NewTee(
+	if level == zap.InfoLevel {
+		// 获取不同日志级别的输出流
+		infoWriteSyncer, err := getWriteSyncer(config, fmt.Sprintf("%s/%s-info.log", config.Directory, loggerName))
+		if err != nil {
+			return nil, err
+		}
+		errorWriteSyncer, err := getWriteSyncer(config, fmt.Sprintf("%s/%s-error.log", config.Directory, loggerName))
+		if err != nil {
+			return nil, err
+		}
+
+		return zapcore.NewTee(
 			zapcore.NewCore(encoder, infoWriteSyncer, zap.InfoLevel),
 			zapcore.NewCore(encoder, errorWriteSyncer, zap.ErrorLevel),
-		)
-	} else {
-		// 获取指定日志级别的输出流
-		writeSyncer := getWriteSyncer(fmt.Sprintf("%s/%s.log", configs.AllConfig.Logger.Directory, loggerName))
+		), nil
+	}
 
-		core = zapcore.NewCore(encoder, writeSyncer, level)
+	// 获取指定日志级别的输出流
+	writeSyncer, err := getWriteSyncer(config, fmt.Sprintf("%s/%s.log", config.Directory, loggerName))
+	if err != nil {
+		return nil, err
 	}
 
-	// 添加调用者信息和行数
-	Logger = zap.New(core, zap.AddCaller())
+	return zapcore.NewCore(encoder, writeSyncer, level), nil
+}
 
-	// 全局替换Zap的日志对象
-	zap.ReplaceGlobals(Logger)
+// 获取输出流，按时间和大小轮换日志文件
+func getWriteSyncer(config configs.LoggerConfig, filename string) (zapcore.WriteSyncer, error) {
+	// 替换日志文件名，采用以log为后缀
+	base := strings.Replace(filename, ".log", "", -1)
 
-	return nil
-}
+	handler := &rotateHandler{
+		glob:     base + ".*.log*",
+		compress: config.Compress,
+		maxAge:   time.Duration(config.MaxAge) * time.Hour,
+		count:    config.RotationCount,
+	}
 
-// 获取输出流
-func getWriteSyncer(filename string) zapcore.WriteSyncer {
-	hook, err := rotatelogs.New(
-		// 替换日志文件名，采用以log为后缀
-		strings.Replace(filename, ".log", "", -1)+".%Y%m%d.log",
+	options := []rotatelogs.Option{
 		// 生产软链接文件
 		rotatelogs.WithLinkName(filename),
 		// 切割日志文件的间隔
-		rotatelogs.WithRotationTime(time.Duration(configs.AllConfig.Logger.RotationTime)*time.Hour),
-		// 等待清理旧日志的时间，此配置为禁用清理
-		rotatelogs.WithMaxAge(-1),
-		// 保留日志文件的个数
-		rotatelogs.WithRotationCount(configs.AllConfig.Logger.RotationCount),
-	)
+		rotatelogs.WithRotationTime(time.Duration(config.RotationTime) * time.Hour),
+		// 压缩和清理轮换出的日志文件
+		rotatelogs.WithHandler(handler),
+	}
+	if config.MaxSize > 0 {
+		// 单个日志文件的大小上限
+		options = append(options, rotatelogs.WithRotationSize(config.MaxSize<<20))
+	}
+	if handler.maxAge > 0 {
+		// 按保留时间清理旧日志
+		options = append(options, rotatelogs.WithMaxAge(handler.maxAge), rotatelogs.WithRotationCount(0))
+	} else {
+		// 按保留个数清理旧日志，禁用按时间清理
+		options = append(options, rotatelogs.WithMaxAge(-1), rotatelogs.WithRotationCount(config.RotationCount))
+	}
 
+	hook, err := rotatelogs.New(base+".%Y%m%d.log", options...)
 	if err != nil {
-		log.Printf("failed to create rotatelogs: %s", err)
-		return nil
+		return nil, fmt.Errorf("failed to create rotatelogs for %s: %w", filename, err)
 	}
 
 	// Zap底层设置了缓存，此方法用于将缓存同步到文件中
-	return zapcore.AddSync(hook)
+	return zapcore.AddSync(hook), nil
 }
 
-// 获取日志编码器
-func getEncoder() zapcore.Encoder {
+// 获取编码器的配置
+func newEncoderConfig() zapcore.EncoderConfig {
 	encoderConfig := zap.NewProductionEncoderConfig()
 
 	// 设置时间格式
@@ -91,8 +175,22 @@ func getEncoder() zapcore.Encoder {
 	// 使用大写字母记录日志级别
 	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 
+	return encoderConfig
+}
+
+// 获取日志编码器
+func getEncoder() zapcore.Encoder {
 	// JSON 编码器
-	return zapcore.NewJSONEncoder(encoderConfig)
+	return zapcore.NewJSONEncoder(newEncoderConfig())
+}
+
+// 判断文件是否为终端
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
 // 将缓存区的日志追加到日志文件中
@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"cupid/pkg/sensitive"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	// 身份证号：17 位数字加 1 位数字或 X，保留前 3 位和后 4 位
+	idCardValuePattern = regexp.MustCompile(`\b(\d{3})\d{11}(\d{3}[\dXx])\b`)
+	// 手机号：以 1 开头的 11 位数字，保留前 3 位和后 4 位
+	phoneValuePattern = regexp.MustCompile(`\b(1[3-9]\d)\d{4}(\d{4})\b`)
+)
+
+// 脱敏字符串中的身份证号和手机号
+func redactString(value string) string {
+	value = idCardValuePattern.ReplaceAllString(value, "$1***********$2")
+	return phoneValuePattern.ReplaceAllString(value, "$1****$2")
+}
+
+// 递归脱敏 zap.Any 记录的对象，返回脱敏后的副本，不修改原对象
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return redactString(v)
+	case json.Number:
+		if redacted := redactString(v.String()); redacted != v.String() {
+			return redacted
+		}
+		return v
+	case map[string]interface{}:
+		identity := false
+		for k := range v {
+			identity = identity || sensitive.Identity(k)
+		}
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if sensitive.Field(k, identity) {
+				result[k] = sensitive.Mask
+				continue
+			}
+			result[k] = redactValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = redactValue(item)
+		}
+		return result
+	}
+
+	// 其他的结构体、映射和切片转换为 JSON 对象后脱敏
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return value
+		}
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.UseNumber()
+		var decoded interface{}
+		if err = decoder.Decode(&decoded); err != nil {
+			return value
+		}
+		return redactValue(decoded)
+	}
+	return value
+}
+
+// 脱敏字段
+func redactField(field zapcore.Field) zapcore.Field {
+	if field.Type == zapcore.SkipType {
+		return field
+	}
+	if sensitive.Key(field.Key) {
+		return zap.String(field.Key, sensitive.Mask)
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		field.String = redactString(field.String)
+	case zapcore.ByteStringType:
+		return zap.String(field.Key, redactString(string(field.Interface.([]byte))))
+	case zapcore.StringerType, zapcore.ErrorType:
+		// 通过编码获取字符串，避免重复处理 nil 和 panic
+		encoder := zapcore.NewMapObjectEncoder()
+		field.AddTo(encoder)
+		if s, ok := encoder.Fields[field.Key].(string); ok {
+			if redacted := redactString(s); redacted != s {
+				return zap.String(field.Key, redacted)
+			}
+		}
+	case zapcore.ReflectType:
+		return zap.Reflect(field.Key, redactValue(field.Interface))
+	case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType:
+		encoder := zapcore.NewMapObjectEncoder()
+		field.AddTo(encoder)
+		return zap.Reflect(field.Key, redactValue(encoder.Fields[field.Key]))
+	}
+	return field
+}
+
+// 脱敏的编码器，在编码前脱敏日志消息和字段
+type redactEncoder struct {
+	zapcore.Encoder
+}
+
+// 包装编码器，enabled 为 false 时原样返回
+func redact(encoder zapcore.Encoder, enabled bool) zapcore.Encoder {
+	if !enabled {
+		return encoder
+	}
+	return &redactEncoder{Encoder: encoder}
+}
+
+func (encoder *redactEncoder) Clone() zapcore.Encoder {
+	return &redactEncoder{Encoder: encoder.Encoder.Clone()}
+}
+
+func (encoder *redactEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	entry.Message = redactString(entry.Message)
+
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = redactField(field)
+	}
+	return encoder.Encoder.EncodeEntry(entry, redacted)
+}
+
+// 以下方法处理 With 添加的字段
+
+func (encoder *redactEncoder) AddString(key, value string) {
+	redactField(zap.String(key, value)).AddTo(encoder.Encoder)
+}
+
+func (encoder *redactEncoder) AddByteString(key string, value []byte) {
+	redactField(zap.ByteString(key, value)).AddTo(encoder.Encoder)
+}
+
+func (encoder *redactEncoder) AddReflected(key string, value interface{}) error {
+	redactField(zap.Reflect(key, value)).AddTo(encoder.Encoder)
+	return nil
+}
+
+func (encoder *redactEncoder) AddArray(key string, value zapcore.ArrayMarshaler) error {
+	redactField(zap.Array(key, value)).AddTo(encoder.Encoder)
+	return nil
+}
+
+func (encoder *redactEncoder) AddObject(key string, value zapcore.ObjectMarshaler) error {
+	redactField(zap.Object(key, value)).AddTo(encoder.Encoder)
+	return nil
+}
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 创建输出到缓冲区的脱敏日志
+func newRedactLogger(enabled bool) (*zap.Logger, *bytes.Buffer) {
+	buffer := new(bytes.Buffer)
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = ""
+	encoder := redact(zapcore.NewJSONEncoder(encoderConfig), enabled)
+	return zap.New(zapcore.NewCore(encoder, zapcore.AddSync(buffer), zap.DebugLevel)), buffer
+}
+
+func TestRedactEncoder(t *testing.T) {
+	cases := []struct {
+		name    string
+		log     func(logger *zap.Logger)
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "id card and phone in message",
+			log: func(logger *zap.Logger) {
+				logger.Info("接种人 510125199801116024，手机号 13812345678")
+			},
+			want:    []string{"510***********6024", "138****5678"},
+			notWant: []string{"510125199801116024", "13812345678"},
+		},
+		{
+			name: "id card and phone in fields",
+			log: func(logger *zap.Logger) {
+				logger.Info("订购疫苗", zap.String("data", "idcard=51012519980111602X"), zap.Error(errPhone("13812345678")))
+			},
+			want:    []string{"510***********602X", "138****5678"},
+			notWant: []string{"51012519980111602X", "13812345678"},
+		},
+		{
+			name: "sensitive keys",
+			log: func(logger *zap.Logger) {
+				logger.Info("请求", zap.String("Set-Cookie", "ASP.NET_SessionId=abc"), zap.String("linkman_id", "18552351"), zap.String("tk", "wxapptoken"))
+			},
+			want:    []string{`"Set-Cookie":"******"`, `"linkman_id":"******"`, `"tk":"******"`},
+			notWant: []string{"abc", "18552351", "wxapptoken"},
+		},
+		{
+			name: "fields added through With",
+			log: func(logger *zap.Logger) {
+				logger.With(zap.String("token", "wxapptoken"), zap.String("message", "13812345678")).Info("请求")
+			},
+			want:    []string{`"token":"******"`, "138****5678"},
+			notWant: []string{"wxapptoken", "13812345678"},
+		},
+		{
+			name: "maps in zap.Any",
+			log: func(logger *zap.Logger) {
+				logger.Info("响应", zap.Any("data", map[string]interface{}{
+					"user": map[string]interface{}{"cname": "张三", "idcard": "510125199801116024", "tel": "13812345678"},
+				}))
+			},
+			want:    []string{`"cname":"******"`, `"idcard":"******"`, `"tel":"******"`},
+			notWant: []string{"张三", "510125199801116024", "13812345678"},
+		},
+		{
+			name: "cname without identity fields",
+			log: func(logger *zap.Logger) {
+				logger.Info("医院", zap.Any("hospital", map[string]interface{}{"id": 7, "cname": "成都市武侯区妇幼保健院", "tel": "028-85559999"}))
+			},
+			want:    []string{`"cname":"成都市武侯区妇幼保健院"`, `"tel":"******"`},
+			notWant: []string{"028-85559999"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			logger, buffer := newRedactLogger(true)
+			c.log(logger)
+
+			output := buffer.String()
+			for _, s := range c.want {
+				if !strings.Contains(output, s) {
+					t.Errorf("output = %s, want it to contain %s", output, s)
+				}
+			}
+			for _, s := range c.notWant {
+				if strings.Contains(output, s) {
+					t.Errorf("output = %s, want it not to contain %s", output, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactDisabled(t *testing.T) {
+	logger, buffer := newRedactLogger(false)
+	logger.Info("接种人", zap.String("token", "wxapptoken"), zap.String("idcard", "510125199801116024"))
+
+	if output := buffer.String(); !strings.Contains(output, "wxapptoken") || !strings.Contains(output, "510125199801116024") {
+		t.Errorf("output = %s, want the original values", output)
+	}
+}
+
+// 包含手机号的错误
+type errPhone string
+
+func (e errPhone) Error() string {
+	return "unable to notify " + string(e)
+}
@@ -0,0 +1,178 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+)
+
+// 压缩后的日志文件的后缀
+const compressedSuffix = ".gz"
+
+// rotatelogs 创建的锁文件和软链接的临时文件的后缀，同样匹配日志文件的模式，清理时跳过
+var auxiliarySuffixes = []string{"_lock", "_symlink"}
+
+// 日志文件轮换后的处理：压缩轮换出的日志文件，并按保留个数或保留时间清理旧的日志文件
+//
+// 按大小轮换出的文件和压缩后的文件不在 rotatelogs 的清理范围内，因此由此处统一清理。
+type rotateHandler struct {
+	mutex    sync.Mutex
+	glob     string        // 匹配该日志所有文件的模式，如 logs/seckill.*.log*
+	compress bool          // 是否压缩轮换出的日志文件
+	maxAge   time.Duration // 保留时间，为 0 时按保留个数清理
+	count    uint          // 保留个数，包括正在写入的文件
+}
+
+func (handler *rotateHandler) Handle(event rotatelogs.Event) {
+	rotated, ok := event.(*rotatelogs.FileRotatedEvent)
+	if !ok {
+		return
+	}
+
+	// rotatelogs 在独立的协程中回调，连续轮换时需要串行处理
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	// 首次创建日志文件时没有轮换出的文件
+	if handler.compress && rotated.PreviousFile() != "" {
+		if err := compressFile(rotated.PreviousFile()); err != nil {
+			log.Printf("failed to compress %s: %s", rotated.PreviousFile(), err)
+		}
+	}
+
+	handler.purge(rotated.CurrentFile())
+}
+
+// 清理旧的日志文件，跳过软链接、rotatelogs 的锁文件和正在写入的文件
+func (handler *rotateHandler) purge(current string) {
+	matches, err := filepath.Glob(handler.glob)
+	if err != nil {
+		return
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	files := make([]logFile, 0, len(matches))
+	for _, path := range matches {
+		if path == current || isAuxiliary(path) {
+			continue
+		}
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		files = append(files, logFile{path: path, modTime: info.ModTime()})
+	}
+
+	// 从新到旧排列
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	cutoff := time.Now().Add(-handler.maxAge)
+	for i, file := range files {
+		// 保留个数包括正在写入的文件
+		expired := handler.maxAge > 0 && file.modTime.Before(cutoff)
+		exceeded := handler.maxAge <= 0 && handler.count > 0 && uint(i+1) >= handler.count
+		if expired || exceeded {
+			if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+				log.Printf("failed to remove %s: %s", file.path, err)
+			}
+		}
+	}
+}
+
+// 判断是否为 rotatelogs 创建的锁文件或临时文件
+func isAuxiliary(path string) bool {
+	for _, suffix := range auxiliarySuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 使用 gzip 压缩文件，压缩成功后删除原文件
+//
+// rotatelogs 按未压缩的文件名选择新文件名，压缩并删除原文件后可能再次使用同一个文件名，
+// 如同一天内重启，此时压缩文件已存在，改为追加序号，如 seckill.20211210.log.1-1.gz。
+func compressFile(path string) (err error) {
+	if strings.HasSuffix(path, compressedSuffix) {
+		return nil
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	// 先写入临时文件，避免压缩中断时留下不完整的压缩文件
+	temporary := path + compressedSuffix + ".tmp"
+	target, err := os.OpenFile(temporary, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			target.Close()
+			os.Remove(temporary)
+		}
+	}()
+
+	writer := gzip.NewWriter(target)
+	writer.Name = filepath.Base(path)
+	writer.ModTime = info.ModTime()
+	if _, err = io.Copy(writer, source); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	if err = target.Close(); err != nil {
+		return err
+	}
+
+	// 保留原文件的修改时间，使清理时按原文件的时间排序
+	if err = os.Chtimes(temporary, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	if err = linkUnique(temporary, path); err != nil {
+		return err
+	}
+	source.Close()
+	os.Remove(temporary)
+	return os.Remove(path)
+}
+
+// 将压缩后的临时文件链接为未被占用的压缩文件名，不覆盖已存在的文件
+func linkUnique(temporary string, path string) error {
+	for i := 0; ; i++ {
+		target := path + compressedSuffix
+		if i > 0 {
+			target = fmt.Sprintf("%s-%d%s", path, i, compressedSuffix)
+		}
+
+		// 目标文件已存在时 Link 失败，不同于 Rename 会覆盖目标文件
+		err := os.Link(temporary, target)
+		if err == nil || !os.IsExist(err) {
+			return err
+		}
+	}
+}
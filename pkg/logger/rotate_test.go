@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 读取压缩文件的内容
+func readCompressed(t *testing.T, path string) string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCompressFileKeepsExistingArchive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "seckill.20211210.log.1")
+
+	// rotatelogs 在压缩后再次使用同一个文件名
+	for _, content := range []string{"first", "second", "third"} {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := compressFile(path); err != nil {
+			t.Fatalf("compressFile() error = %v", err)
+		}
+	}
+
+	want := map[string]string{
+		"seckill.20211210.log.1.gz":   "first",
+		"seckill.20211210.log.1-1.gz": "second",
+		"seckill.20211210.log.1-2.gz": "third",
+	}
+	for name, content := range want {
+		if got := readCompressed(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("files = %v, want only the archives", names)
+	}
+}
+
+func TestPurgeSkipsLockFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := []string{
+		"seckill.20211208.log.gz",
+		"seckill.20211209.log.gz",
+		"seckill.20211210.log_lock",
+		"seckill.20211210.log",
+	}
+	for i, name := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(time.Duration(i-len(files)) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := &rotateHandler{glob: filepath.Join(dir, "seckill.*.log*"), count: 2}
+	handler.purge(filepath.Join(dir, "seckill.20211210.log"))
+
+	for name, exist := range map[string]bool{
+		"seckill.20211208.log.gz":   false,
+		"seckill.20211209.log.gz":   true,
+		"seckill.20211210.log_lock": true,
+		"seckill.20211210.log":      true,
+	} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != exist {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, exist)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logger
+
+import (
+	"cupid/pkg/configs"
+	"fmt"
+	"log/syslog"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 未指定标签时使用的 syslog 标签
+const defaultSyslogTag = "cupid"
+
+// 创建 syslog 的日志核心，未指定网络类型时连接本机的 syslog 套接字
+func newSyslogCore(config configs.LoggerConfig) (zapcore.Core, error) {
+	level, err := getLevel(config, config.Syslog.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := config.Syslog.Tag
+	if tag == "" {
+		tag = defaultSyslogTag
+	}
+
+	writer, err := syslog.Dial(config.Syslog.Network, config.Syslog.Address, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to syslog: %w", err)
+	}
+
+	// syslog 自带时间戳
+	encoderConfig := newEncoderConfig()
+	encoderConfig.TimeKey = ""
+
+	return &syslogCore{
+		LevelEnabler: level,
+		encoder:      redact(zapcore.NewJSONEncoder(encoderConfig), config.Redact),
+		writer:       writer,
+	}, nil
+}
+
+// syslog 的日志核心，按日志级别设置 syslog 的优先级
+type syslogCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	writer  *syslog.Writer
+}
+
+func (core *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &syslogCore{
+		LevelEnabler: core.LevelEnabler,
+		encoder:      core.encoder.Clone(),
+		writer:       core.writer,
+	}
+	for _, field := range fields {
+		field.AddTo(clone.encoder)
+	}
+	return clone
+}
+
+func (core *syslogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if core.Enabled(entry.Level) {
+		return checked.AddCore(entry, core)
+	}
+	return checked
+}
+
+func (core *syslogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buffer, err := core.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	defer buffer.Free()
+
+	message := strings.TrimSuffix(buffer.String(), "\n")
+	switch entry.Level {
+	case zap.DebugLevel:
+		return core.writer.Debug(message)
+	case zap.InfoLevel:
+		return core.writer.Info(message)
+	case zap.WarnLevel:
+		return core.writer.Warning(message)
+	case zap.ErrorLevel:
+		return core.writer.Err(message)
+	default:
+		// DPanic、Panic 和 Fatal 均为 Crit，Emerg 会广播到所有已登录的终端
+		return core.writer.Crit(message)
+	}
+}
+
+func (core *syslogCore) Sync() error {
+	return nil
+}
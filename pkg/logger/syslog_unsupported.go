@@ -0,0 +1,17 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package logger
+
+import (
+	"cupid/pkg/configs"
+	"errors"
+	"runtime"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 当前平台不支持 syslog
+func newSyslogCore(config configs.LoggerConfig) (zapcore.Core, error) {
+	return nil, errors.New("syslog is not supported on " + runtime.GOOS)
+}
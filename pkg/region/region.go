@@ -0,0 +1,299 @@
+package region
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// 区域的分隔符，如"四川省-成都市-武侯区"
+const Separator = "-"
+
+// 排除区域的前缀，如"!四川省-甘孜藏族自治州"
+const ExcludePrefix = "!"
+
+// 待嗅探的区域
+type Area struct {
+	Province  string                 // 省份
+	City      string                 // 城市
+	Code      string                 // 城市编码
+	Location  map[string]interface{} // 经纬度
+	Districts []string               // 区县，为空时表示整个城市
+}
+
+// 区域匹配失败的错误，汇总所有存在问题的区域
+type SelectError struct {
+	Problems []string
+}
+
+func (e *SelectError) Error() string {
+	return fmt.Sprintf("invalid regions: %s", strings.Join(e.Problems, "; "))
+}
+
+// 区域规则
+type rule struct {
+	raw     string   // 原始配置
+	exclude bool     // 是否为排除规则
+	parts   []string // 省、市、区县的匹配模式
+}
+
+// 城市编码文件中的省份
+type province struct {
+	name   string
+	cities []Area
+}
+
+// 根据区域规则从城市编码中选择待嗅探的区域
+//
+// 规则支持省、市和区县三个粒度，以"-"分隔，每一级均支持"*"等通配符；
+// 以"!"开头的规则表示排除，排除规则在所有选择规则之后生效；
+// 规则为空或只有排除规则时，默认选择所有区域。
+func Select(catalog map[string]interface{}, patterns []string) ([]Area, error) {
+	provinces, err := parseCatalog(catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	// 解析规则
+	problems := make([]string, 0)
+	includes := make([]rule, 0)
+	excludes := make([]rule, 0)
+	for _, pattern := range patterns {
+		r, err := parseRule(pattern)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if r.exclude {
+			excludes = append(excludes, r)
+		} else {
+			includes = append(includes, r)
+		}
+	}
+	if len(includes) <= 0 {
+		includes = append(includes, rule{raw: "*", parts: []string{"*"}})
+	}
+
+	// 已选择的区域，按城市编码文件中的顺序输出
+	selected := make(map[string]*Area)
+	order := make([]string, 0)
+
+	for _, r := range includes {
+		matched := 0
+		for _, p := range provinces {
+			for _, city := range p.cities {
+				if !r.match(city) {
+					continue
+				}
+				matched++
+
+				key := city.Province + Separator + city.City
+				area, exist := selected[key]
+				if !exist {
+					area = &Area{Province: city.Province, City: city.City, Code: city.Code, Location: city.Location}
+					selected[key] = area
+					order = append(order, key)
+					if district, ok := r.district(); ok {
+						area.Districts = []string{district}
+					}
+					continue
+				}
+
+				// 已选择整个城市时，忽略区县规则
+				if len(area.Districts) <= 0 {
+					continue
+				}
+				if district, ok := r.district(); ok {
+					area.Districts = appendUnique(area.Districts, district)
+				} else {
+					area.Districts = nil
+				}
+			}
+		}
+		if matched <= 0 {
+			problems = append(problems, fmt.Sprintf("%q matches no city", r.raw))
+		}
+	}
+
+	for _, r := range excludes {
+		if _, ok := r.district(); ok {
+			problems = append(problems, fmt.Sprintf("%q: excluding a district is not supported", r.raw))
+			continue
+		}
+
+		matched := 0
+		for _, p := range provinces {
+			for _, city := range p.cities {
+				if r.match(city) {
+					matched++
+					delete(selected, city.Province+Separator+city.City)
+				}
+			}
+		}
+		if matched <= 0 {
+			problems = append(problems, fmt.Sprintf("%q matches no city", r.raw))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &SelectError{Problems: problems}
+	}
+
+	areas := make([]Area, 0, len(selected))
+	for _, key := range order {
+		if area, ok := selected[key]; ok {
+			areas = append(areas, *area)
+		}
+	}
+	return areas, nil
+}
+
+// 合并多组区域，按第一次出现的顺序输出
+//
+// 同一城市在任意一组中为整个城市时合并为整个城市，否则合并各组的区县。
+func Union(groups ...[]Area) []Area {
+	merged := make(map[string]*Area)
+	order := make([]string, 0)
+	for _, areas := range groups {
+		for _, area := range areas {
+			key := area.Province + Separator + area.City
+			existing, ok := merged[key]
+			if !ok {
+				copied := area
+				copied.Districts = append([]string(nil), area.Districts...)
+				merged[key] = &copied
+				order = append(order, key)
+				continue
+			}
+
+			if len(existing.Districts) <= 0 {
+				continue
+			}
+			if len(area.Districts) <= 0 {
+				existing.Districts = nil
+				continue
+			}
+			for _, district := range area.Districts {
+				existing.Districts = appendUnique(existing.Districts, district)
+			}
+		}
+	}
+
+	areas := make([]Area, 0, len(order))
+	for _, key := range order {
+		areas = append(areas, *merged[key])
+	}
+	return areas
+}
+
+// 判断地址是否位于区域指定的区县中，未指定区县时总是匹配
+func (area Area) MatchDistrict(address string) bool {
+	if len(area.Districts) <= 0 {
+		return true
+	}
+	for _, district := range area.Districts {
+		if strings.Contains(address, district) {
+			return true
+		}
+	}
+	return false
+}
+
+// 按省份对区域进行分组
+func GroupByProvince(areas []Area) map[string][]Area {
+	groups := make(map[string][]Area)
+	for _, area := range areas {
+		groups[area.Province] = append(groups[area.Province], area)
+	}
+	return groups
+}
+
+// 解析区域规则
+func parseRule(pattern string) (rule, error) {
+	r := rule{raw: pattern}
+
+	value := strings.TrimSpace(pattern)
+	if strings.HasPrefix(value, ExcludePrefix) {
+		r.exclude = true
+		value = strings.TrimSpace(strings.TrimPrefix(value, ExcludePrefix))
+	}
+
+	r.parts = strings.Split(value, Separator)
+	if len(r.parts) > 3 {
+		return r, fmt.Errorf("%q: expected at most province, city and district", pattern)
+	}
+	for i, part := range r.parts {
+		r.parts[i] = strings.TrimSpace(part)
+		if r.parts[i] == "" {
+			return r, fmt.Errorf("%q: empty region name", pattern)
+		}
+		if _, err := path.Match(r.parts[i], ""); err != nil {
+			return r, fmt.Errorf("%q: %w", pattern, err)
+		}
+	}
+
+	return r, nil
+}
+
+// 判断规则是否匹配指定的城市
+func (r rule) match(city Area) bool {
+	if ok, _ := path.Match(r.parts[0], city.Province); !ok {
+		return false
+	}
+	if len(r.parts) >= 2 {
+		if ok, _ := path.Match(r.parts[1], city.City); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// 获取规则中的区县，区县为"*"时表示整个城市
+func (r rule) district() (string, bool) {
+	if len(r.parts) < 3 || r.parts[2] == "*" {
+		return "", false
+	}
+	return r.parts[2], true
+}
+
+// 解析城市编码，省份按名称排序
+func parseCatalog(catalog map[string]interface{}) ([]province, error) {
+	provinces := make([]province, 0, len(catalog))
+	for name, v := range catalog {
+		cities, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid city catalog: province %q is not a list", name)
+		}
+
+		p := province{name: name, cities: make([]Area, 0, len(cities))}
+		for _, vv := range cities {
+			city, ok := vv.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid city catalog: city in %q is not an object", name)
+			}
+
+			area := Area{Province: name}
+			area.City, _ = city["name"].(string)
+			area.Code = fmt.Sprintf("%v", city["value"])
+			area.Location, _ = city["location"].(map[string]interface{})
+			p.cities = append(p.cities, area)
+		}
+		provinces = append(provinces, p)
+	}
+
+	sort.Slice(provinces, func(i, j int) bool {
+		return provinces[i].name < provinces[j].name
+	})
+	return provinces, nil
+}
+
+// 追加不重复的元素
+func appendUnique(items []string, item string) []string {
+	for _, v := range items {
+		if v == item {
+			return items
+		}
+	}
+	return append(items, item)
+}
@@ -0,0 +1,206 @@
+package region
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 测试使用的城市编码
+func testCatalog() map[string]interface{} {
+	city := func(name string, value string) interface{} {
+		return map[string]interface{}{"name": name, "value": value, "location": map[string]interface{}{"lat": 30.0, "lng": 104.0}}
+	}
+	return map[string]interface{}{
+		"四川省": []interface{}{city("成都市", "5101"), city("绵阳市", "5107"), city("甘孜藏族自治州", "5133")},
+		"直辖市": []interface{}{city("重庆市", "50"), city("北京市", "11")},
+	}
+}
+
+// 将区域格式化为"省-市"，指定区县时追加"[区县,区县]"
+func formatAreas(areas []Area) []string {
+	result := make([]string, 0, len(areas))
+	for _, area := range areas {
+		s := area.Province + Separator + area.City
+		if len(area.Districts) > 0 {
+			s += "[" + strings.Join(area.Districts, ",") + "]"
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+func TestSelect(t *testing.T) {
+	cases := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{
+			name: "empty selects all",
+			want: []string{"四川省-成都市", "四川省-绵阳市", "四川省-甘孜藏族自治州", "直辖市-重庆市", "直辖市-北京市"},
+		},
+		{
+			name:     "province",
+			patterns: []string{"四川省"},
+			want:     []string{"四川省-成都市", "四川省-绵阳市", "四川省-甘孜藏族自治州"},
+		},
+		{
+			name:     "wildcard province",
+			patterns: []string{"*-成都市"},
+			want:     []string{"四川省-成都市"},
+		},
+		{
+			name:     "wildcard city",
+			patterns: []string{"四川省-*阳市", "直辖市-*"},
+			want:     []string{"四川省-绵阳市", "直辖市-重庆市", "直辖市-北京市"},
+		},
+		{
+			name:     "exclude",
+			patterns: []string{"四川省", "!四川省-甘孜藏族自治州"},
+			want:     []string{"四川省-成都市", "四川省-绵阳市"},
+		},
+		{
+			name:     "exclude before include",
+			patterns: []string{"!直辖市-北京市", "直辖市"},
+			want:     []string{"直辖市-重庆市"},
+		},
+		{
+			name:     "exclude only selects the rest",
+			patterns: []string{"!直辖市", "!*-绵阳市"},
+			want:     []string{"四川省-成都市", "四川省-甘孜藏族自治州"},
+		},
+		{
+			name:     "districts are merged",
+			patterns: []string{"四川省-成都市-武侯区", "四川省-成都市-锦江区", "四川省-成都市-武侯区"},
+			want:     []string{"四川省-成都市[武侯区,锦江区]"},
+		},
+		{
+			name:     "whole city after district",
+			patterns: []string{"四川省-成都市-武侯区", "四川省-成都市"},
+			want:     []string{"四川省-成都市"},
+		},
+		{
+			name:     "district after whole city",
+			patterns: []string{"四川省-成都市", "四川省-成都市-武侯区"},
+			want:     []string{"四川省-成都市"},
+		},
+		{
+			name:     "wildcard district is the whole city",
+			patterns: []string{"四川省-成都市-武侯区", "*-成都市-*"},
+			want:     []string{"四川省-成都市"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			areas, err := Select(testCatalog(), c.patterns)
+			if err != nil {
+				t.Fatalf("Select() error = %v", err)
+			}
+			if got := formatAreas(areas); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Select() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{
+			name:     "matches nothing",
+			patterns: []string{"四川省", "西藏自治区"},
+			want:     []string{`"西藏自治区" matches no city`},
+		},
+		{
+			name:     "exclude matches nothing",
+			patterns: []string{"!四川省-攀枝花市"},
+			want:     []string{`"!四川省-攀枝花市" matches no city`},
+		},
+		{
+			name:     "exclude district",
+			patterns: []string{"四川省", "!四川省-成都市-武侯区"},
+			want:     []string{`"!四川省-成都市-武侯区": excluding a district is not supported`},
+		},
+		{
+			name:     "too many levels",
+			patterns: []string{"四川省-成都市-武侯区-玉林街道"},
+			want:     []string{`"四川省-成都市-武侯区-玉林街道": expected at most province, city and district`},
+		},
+		{
+			name:     "empty level",
+			patterns: []string{"四川省-"},
+			want:     []string{`"四川省-": empty region name`},
+		},
+		{
+			name:     "all problems are reported",
+			patterns: []string{"西藏自治区", "*-攀枝花市"},
+			want:     []string{`"西藏自治区" matches no city`, `"*-攀枝花市" matches no city`},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Select(testCatalog(), c.patterns)
+			var selectError *SelectError
+			if !errors.As(err, &selectError) {
+				t.Fatalf("Select() error = %v, want *SelectError", err)
+			}
+			if !reflect.DeepEqual(selectError.Problems, c.want) {
+				t.Errorf("Select() problems = %q, want %q", selectError.Problems, c.want)
+			}
+		})
+	}
+}
+
+func TestSelectInvalidPattern(t *testing.T) {
+	if _, err := Select(testCatalog(), []string{"四川省-[成都"}); err == nil {
+		t.Error("Select() error = nil, want an error for the malformed pattern")
+	}
+}
+
+func TestMatchDistrict(t *testing.T) {
+	area := Area{Province: "四川省", City: "成都市", Districts: []string{"武侯区", "锦江区"}}
+	if !area.MatchDistrict("四川省成都市武侯区洗面桥街8号") {
+		t.Error("MatchDistrict() = false for an address in 武侯区")
+	}
+	if area.MatchDistrict("四川省成都市青羊区青华路32号") {
+		t.Error("MatchDistrict() = true for an address in 青羊区")
+	}
+	if !(Area{City: "成都市"}).MatchDistrict("四川省成都市青羊区青华路32号") {
+		t.Error("MatchDistrict() = false for a city without districts")
+	}
+}
+
+func TestUnion(t *testing.T) {
+	catalog := testCatalog()
+	selectAreas := func(patterns ...string) []Area {
+		areas, err := Select(catalog, patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return areas
+	}
+
+	// 一组的排除不影响另一组选择的城市
+	got := formatAreas(Union(
+		selectAreas("四川省", "!四川省-成都市"),
+		selectAreas("四川省-成都市-武侯区"),
+		selectAreas("四川省-成都市-锦江区", "直辖市-重庆市"),
+	))
+	want := []string{"四川省-绵阳市", "四川省-甘孜藏族自治州", "四川省-成都市[武侯区,锦江区]", "直辖市-重庆市"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Union() = %v, want %v", got, want)
+	}
+
+	// 任意一组为整个城市时合并为整个城市
+	got = formatAreas(Union(selectAreas("四川省-成都市-武侯区"), selectAreas("*-成都市")))
+	if want = []string{"四川省-成都市"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Union() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 最短的休息时间，避免阶梯配置为 0 时空转
+const minInterval = time.Millisecond
+
+// 秒杀时间已过，且已超出持续发起请求的时间
+var ErrWindowClosed = errors.New("seckill window has closed")
+
+// 时钟，可注入模拟的时钟以便在测试中跳过等待
+type Clock interface {
+	// 获取当前时间
+	Now() time.Time
+
+	// 休眠指定的时间，上下文被取消时提前返回
+	Sleep(ctx context.Context, duration time.Duration) error
+}
+
+// 轮询阶梯的一级：距离秒杀开始的时间大于 Remaining 时，每次休息 Interval
+type Step struct {
+	Remaining time.Duration
+	Interval  time.Duration
+}
+
+// 发射窗口内发起请求的节奏：窗口打开时立即发起 Burst 个请求，之后每隔 Interval 发起一个请求
+type Window struct {
+	Interval time.Duration
+	Burst    int
+}
+
+// 秒杀的时间配置
+type Timing struct {
+	Lead   time.Duration // 在秒杀开始前提前发起请求的时间
+	Ladder []Step        // 轮询阶梯，距离秒杀开始越近，休息时间越短
+	Window Window        // 发射窗口内发起请求的节奏
+	End    time.Duration // 秒杀开始后持续发起请求的时间
+}
+
+// 默认的时间配置
+var DefaultTiming = Timing{
+	Lead: 400 * time.Millisecond,
+	Ladder: []Step{
+		{Remaining: time.Hour, Interval: 30 * time.Minute},
+		{Remaining: 10 * time.Minute, Interval: 5 * time.Minute},
+		{Remaining: 10 * time.Second, Interval: 5 * time.Second},
+		{Remaining: 3 * time.Second, Interval: time.Second},
+		{Remaining: 1200 * time.Millisecond, Interval: 100 * time.Millisecond},
+		{Remaining: 0, Interval: 50 * time.Millisecond},
+	},
+	Window: Window{Interval: 250 * time.Millisecond, Burst: 4},
+	End:    10 * time.Second,
+}
+
+// 秒杀的倒计时调度器
+type Scheduler struct {
+	timing Timing
+	clock  Clock
+}
+
+// 创建调度器，时间配置中未设置的字段使用默认值
+func New(timing Timing, clock Clock) *Scheduler {
+	if timing.Lead <= 0 {
+		timing.Lead = DefaultTiming.Lead
+	}
+	if len(timing.Ladder) <= 0 {
+		timing.Ladder = DefaultTiming.Ladder
+	}
+	if timing.Window.Interval <= 0 {
+		timing.Window.Interval = DefaultTiming.Window.Interval
+	}
+	if timing.Window.Burst <= 0 {
+		timing.Window.Burst = DefaultTiming.Window.Burst
+	}
+	if timing.End <= 0 {
+		timing.End = DefaultTiming.End
+	}
+
+	// 阶梯按距离秒杀开始的时间从大到小排列
+	ladder := make([]Step, len(timing.Ladder))
+	copy(ladder, timing.Ladder)
+	sort.SliceStable(ladder, func(i, j int) bool {
+		return ladder[i].Remaining > ladder[j].Remaining
+	})
+	timing.Ladder = ladder
+
+	return &Scheduler{timing: timing, clock: clock}
+}
+
+// 获取调度器使用的时间配置
+func (scheduler *Scheduler) Timing() Timing {
+	return scheduler.timing
+}
+
+// 等待直到发射窗口打开，即距离秒杀开始的时间不超过提前发起请求的时间
+//
+// 秒杀已开始但仍在持续发起请求的时间内时立即返回，超出后返回 ErrWindowClosed。
+func (scheduler *Scheduler) Wait(ctx context.Context, startTime time.Time) error {
+	for {
+		now := scheduler.clock.Now()
+		remaining := startTime.Sub(now)
+
+		if now.Sub(startTime) >= scheduler.timing.End {
+			return ErrWindowClosed
+		}
+		if remaining <= scheduler.timing.Lead {
+			zap.L().Info("发射窗口已打开", zap.Duration("剩余时间", remaining))
+			return nil
+		}
+
+		// 休息时间不超过距离发射窗口打开的时间，避免错过秒杀
+		interval := scheduler.interval(remaining)
+		if interval < minInterval {
+			interval = minInterval
+		}
+		if untilFire := remaining - scheduler.timing.Lead; interval > untilFire {
+			interval = untilFire
+		}
+		zap.L().Info("等待秒杀开始", zap.Duration("剩余时间", remaining), zap.Duration("休息时间", interval), zap.Duration("提前时间", scheduler.timing.Lead))
+
+		if err := scheduler.clock.Sleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// 在发射窗口内按节奏发起请求，直到超出持续发起请求的时间、请求返回 true 或上下文被取消
+//
+// 每个请求在独立的协程中执行，返回前等待所有请求结束，返回发起的请求数。
+func (scheduler *Scheduler) Fire(ctx context.Context, startTime time.Time, attempt func(ctx context.Context) bool) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// 任意一个请求成功后停止发起请求
+	var succeeded int32
+
+	count := 0
+	for {
+		if atomic.LoadInt32(&succeeded) == 1 {
+			zap.L().Info("请求成功，停止发起请求", zap.Int("请求数", count))
+			return count, nil
+		}
+		if scheduler.clock.Now().Sub(startTime) >= scheduler.timing.End {
+			zap.L().Info("秒杀活动已结束", zap.Int("请求数", count))
+			return count, nil
+		}
+
+		count++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if attempt(ctx) {
+				atomic.StoreInt32(&succeeded, 1)
+				cancel()
+			}
+		}()
+
+		// 窗口打开时立即发起多个请求
+		if count < scheduler.timing.Window.Burst {
+			continue
+		}
+		if err := scheduler.clock.Sleep(ctx, scheduler.timing.Window.Interval); err != nil {
+			if atomic.LoadInt32(&succeeded) == 1 {
+				continue
+			}
+			return count, err
+		}
+	}
+}
+
+// 根据距离秒杀开始的时间获取休息时间
+func (scheduler *Scheduler) interval(remaining time.Duration) time.Duration {
+	for _, step := range scheduler.timing.Ladder {
+		if remaining > step.Remaining {
+			return step.Interval
+		}
+	}
+	return scheduler.timing.Ladder[len(scheduler.timing.Ladder)-1].Interval
+}
@@ -0,0 +1,203 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 模拟的时钟，休眠时直接推进时间并记录休眠的时长
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+
+	// 为 true 时休眠直到上下文被取消，用于等待请求成功
+	blockUntilDone bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 12, 10, 9, 0, 0, 0, time.Local)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, duration time.Duration) error {
+	if c.blockUntilDone {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(duration)
+	c.sleeps = append(c.sleeps, duration)
+	return nil
+}
+
+func (c *fakeClock) Sleeps() []time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]time.Duration(nil), c.sleeps...)
+}
+
+func TestWaitFollowsLadder(t *testing.T) {
+	clock := newFakeClock()
+	timing := Timing{
+		Lead: 2 * time.Second,
+		// 乱序的阶梯也按距离秒杀开始的时间从大到小使用
+		Ladder: []Step{
+			{Remaining: 0, Interval: time.Second},
+			{Remaining: 10 * time.Second, Interval: 5 * time.Second},
+		},
+		End: 10 * time.Second,
+	}
+	startTime := clock.Now().Add(20 * time.Second)
+
+	if err := New(timing, clock).Wait(context.Background(), startTime); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	want := []time.Duration{5 * time.Second, 5 * time.Second}
+	for i := 0; i < 8; i++ {
+		want = append(want, time.Second)
+	}
+	if got := clock.Sleeps(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sleeps = %v, want %v", got, want)
+	}
+	if remaining := startTime.Sub(clock.Now()); remaining != timing.Lead {
+		t.Errorf("window opened %s before start, want %s", remaining, timing.Lead)
+	}
+}
+
+func TestWaitDoesNotOversleepLead(t *testing.T) {
+	clock := newFakeClock()
+	timing := Timing{
+		Lead:   400 * time.Millisecond,
+		Ladder: []Step{{Remaining: 0, Interval: time.Minute}},
+	}
+	startTime := clock.Now().Add(1500 * time.Millisecond)
+
+	if err := New(timing, clock).Wait(context.Background(), startTime); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	want := []time.Duration{1100 * time.Millisecond}
+	if got := clock.Sleeps(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sleeps = %v, want %v", got, want)
+	}
+}
+
+func TestWaitWithinWindow(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := New(Timing{End: 10 * time.Second}, clock)
+
+	// 秒杀已开始但仍在持续发起请求的时间内
+	if err := scheduler.Wait(context.Background(), clock.Now().Add(-5*time.Second)); err != nil {
+		t.Errorf("Wait() error = %v, want nil", err)
+	}
+	if sleeps := clock.Sleeps(); len(sleeps) != 0 {
+		t.Errorf("sleeps = %v, want none", sleeps)
+	}
+
+	// 已超出持续发起请求的时间
+	if err := scheduler.Wait(context.Background(), clock.Now().Add(-10*time.Second)); !errors.Is(err, ErrWindowClosed) {
+		t.Errorf("Wait() error = %v, want ErrWindowClosed", err)
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	clock := newFakeClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := New(DefaultTiming, clock).Wait(ctx, clock.Now().Add(time.Hour)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestFireBurstAndEnd(t *testing.T) {
+	clock := newFakeClock()
+	timing := Timing{
+		Window: Window{Interval: 100 * time.Millisecond, Burst: 3},
+		End:    time.Second,
+	}
+
+	var attempts int32
+	count, err := New(timing, clock).Fire(context.Background(), clock.Now(), func(ctx context.Context) bool {
+		atomic.AddInt32(&attempts, 1)
+		return false
+	})
+	if err != nil {
+		t.Fatalf("Fire() error = %v", err)
+	}
+
+	// 窗口打开时立即发起 3 个请求，之后每 100ms 发起 1 个，直到 1s 后结束
+	if count != 12 {
+		t.Errorf("count = %d, want 12", count)
+	}
+	if got := atomic.LoadInt32(&attempts); int(got) != count {
+		t.Errorf("attempts = %d, want %d", got, count)
+	}
+
+	sleeps := clock.Sleeps()
+	if len(sleeps) != 10 {
+		t.Fatalf("sleeps = %v, want 10 sleeps of 100ms", sleeps)
+	}
+	for _, sleep := range sleeps {
+		if sleep != timing.Window.Interval {
+			t.Errorf("sleep = %s, want %s", sleep, timing.Window.Interval)
+		}
+	}
+}
+
+func TestFireStopsOnSuccess(t *testing.T) {
+	clock := newFakeClock()
+	clock.blockUntilDone = true
+	timing := Timing{
+		Window: Window{Interval: 100 * time.Millisecond, Burst: 1},
+		End:    time.Hour,
+	}
+
+	count, err := New(timing, clock).Fire(context.Background(), clock.Now(), func(ctx context.Context) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Fire() error = %v, want nil after a successful attempt", err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+}
+
+func TestFireCanceled(t *testing.T) {
+	clock := newFakeClock()
+	clock.blockUntilDone = true
+	ctx, cancel := context.WithCancel(context.Background())
+
+	timing := Timing{
+		Window: Window{Interval: 100 * time.Millisecond, Burst: 1},
+		End:    time.Hour,
+	}
+	count, err := New(timing, clock).Fire(ctx, clock.Now(), func(context.Context) bool {
+		cancel()
+		return false
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Fire() error = %v, want context.Canceled", err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+}
@@ -0,0 +1,315 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// 环境变量
+const (
+	PassphraseEnv = "CUPID_SECRETS_PASSPHRASE" // 口令
+	KeyEnv        = "CUPID_SECRETS_KEY"        // Base64 编码的 32 字节密钥，优先于口令
+)
+
+// 默认的密钥文件
+const DefaultFile = "./secrets.enc"
+
+// 配置项中引用密钥的前缀，如 secret://ym.token
+const Scheme = "secret://"
+
+// 口令派生密钥的参数
+const (
+	kdfPBKDF2     = "pbkdf2-sha256"
+	kdfRaw        = "raw"
+	keyLength     = 32
+	saltLength    = 16
+	keyIterations = 200000
+)
+
+// 口令或密钥错误，或文件已损坏
+var ErrDecrypt = errors.New("unable to decrypt secrets, wrong passphrase or key")
+
+// 密钥不存在
+var ErrNotFound = errors.New("secret not found")
+
+// 加密文件的格式
+type envelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`                  // 密钥的来源：口令派生或直接使用密钥
+	Iterations int    `json:"iterations,omitempty"` // 口令派生的迭代次数
+	Salt       string `json:"salt,omitempty"`       // 口令派生的盐
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// 解锁密钥文件的凭证：口令或密钥
+type Credential struct {
+	passphrase []byte
+	key        []byte
+}
+
+// 使用口令作为凭证
+func Passphrase(passphrase string) Credential {
+	return Credential{passphrase: []byte(passphrase)}
+}
+
+// 使用 32 字节的密钥作为凭证
+func Key(key []byte) (Credential, error) {
+	if len(key) != keyLength {
+		return Credential{}, fmt.Errorf("key must be %d bytes, got %d", keyLength, len(key))
+	}
+	return Credential{key: key}, nil
+}
+
+// 从环境变量中获取凭证，均未设置时从终端读取口令
+func CredentialFromEnv(prompt io.Writer, input io.Reader) (Credential, error) {
+	return credentialFromEnv(prompt, input, false)
+}
+
+// 从环境变量中获取新建密钥文件的凭证，均未设置时从终端读取两次口令，避免输错后无法解密
+func NewCredentialFromEnv(prompt io.Writer, input io.Reader) (Credential, error) {
+	return credentialFromEnv(prompt, input, true)
+}
+
+// 从环境变量中获取凭证，confirm 为 true 时从终端读取的口令需再次输入确认
+func credentialFromEnv(prompt io.Writer, input io.Reader, confirm bool) (Credential, error) {
+	if value := os.Getenv(KeyEnv); value != "" {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return Credential{}, fmt.Errorf("invalid %s: %w", KeyEnv, err)
+		}
+		return Key(key)
+	}
+	if value := os.Getenv(PassphraseEnv); value != "" {
+		return Passphrase(value), nil
+	}
+
+	passphrase, err := PromptSecret(prompt, input, "Secrets passphrase: ")
+	if err != nil {
+		return Credential{}, fmt.Errorf("unable to read passphrase: %w", err)
+	}
+	if passphrase == "" {
+		return Credential{}, fmt.Errorf("passphrase must not be empty, or set %s or %s", PassphraseEnv, KeyEnv)
+	}
+	if confirm {
+		repeated, err := PromptSecret(prompt, input, "Repeat passphrase: ")
+		if err != nil {
+			return Credential{}, fmt.Errorf("unable to read passphrase: %w", err)
+		}
+		if repeated != passphrase {
+			return Credential{}, fmt.Errorf("passphrases do not match")
+		}
+	}
+	return Passphrase(passphrase), nil
+}
+
+// 输出提示并读取一行输入，逐字节读取，不会多读取后续的输入
+func Prompt(prompt io.Writer, input io.Reader, label string) (string, error) {
+	fmt.Fprint(prompt, label)
+
+	var builder strings.Builder
+	buffer := make([]byte, 1)
+	for {
+		n, err := input.Read(buffer)
+		if n > 0 {
+			if buffer[0] == '\n' {
+				break
+			}
+			builder.WriteByte(buffer[0])
+		}
+		if err == io.EOF && builder.Len() > 0 {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimRight(builder.String(), "\r"), nil
+}
+
+// 加密的密钥文件，所有密钥加密为一个整体
+type Store struct {
+	path       string
+	credential Credential
+	values     map[string]string
+}
+
+// 打开密钥文件，文件不存在时返回空的密钥库，保存时创建
+func Open(path string, credential Credential) (*Store, error) {
+	store := &Store{path: path, credential: credential, values: make(map[string]string)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var e envelope
+	if err = json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("invalid secrets file %s: %w", path, err)
+	}
+
+	plaintext, err := decrypt(e, credential)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(plaintext, &store.values); err != nil {
+		return nil, fmt.Errorf("invalid secrets file %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// 获取密钥
+func (store *Store) Get(name string) (string, error) {
+	value, ok := store.values[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// 设置密钥，需调用 Save 保存
+func (store *Store) Set(name string, value string) {
+	store.values[name] = value
+}
+
+// 所有密钥的名称
+func (store *Store) Names() []string {
+	names := make([]string, 0, len(store.values))
+	for name := range store.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 加密并保存到密钥文件，每次保存使用新的盐和随机数
+func (store *Store) Save() error {
+	plaintext, err := json.Marshal(store.values)
+	if err != nil {
+		return err
+	}
+
+	e, err := encrypt(plaintext, store.credential)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(store.path); dir != "" {
+		if err = os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	temp := store.path + ".tmp"
+	if err = ioutil.WriteFile(temp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, store.path)
+}
+
+// 加密
+func encrypt(plaintext []byte, credential Credential) (envelope, error) {
+	e := envelope{Version: 1}
+
+	key := credential.key
+	if key == nil {
+		salt := make([]byte, saltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return e, err
+		}
+		e.KDF, e.Iterations, e.Salt = kdfPBKDF2, keyIterations, base64.StdEncoding.EncodeToString(salt)
+		key = deriveKey(credential.passphrase, salt, keyIterations)
+	} else {
+		e.KDF = kdfRaw
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return e, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return e, err
+	}
+
+	e.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	e.Ciphertext = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, []byte(e.KDF)))
+	return e, nil
+}
+
+// 解密
+func decrypt(e envelope, credential Credential) ([]byte, error) {
+	var key []byte
+	switch e.KDF {
+	case kdfPBKDF2:
+		if credential.passphrase == nil {
+			return nil, fmt.Errorf("%w: the file is protected by a passphrase, set %s", ErrDecrypt, PassphraseEnv)
+		}
+		salt, err := base64.StdEncoding.DecodeString(e.Salt)
+		if err != nil {
+			return nil, err
+		}
+		key = deriveKey(credential.passphrase, salt, e.Iterations)
+	case kdfRaw:
+		if credential.key == nil {
+			return nil, fmt.Errorf("%w: the file is protected by a key, set %s", ErrDecrypt, KeyEnv)
+		}
+		key = credential.key
+	default:
+		return nil, fmt.Errorf("unsupported kdf %q", e.KDF)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(e.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(e.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(e.KDF))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// 使用 PBKDF2-HMAC-SHA256 从口令派生密钥
+func deriveKey(passphrase []byte, salt []byte, iterations int) []byte {
+	return pbkdf2.Key(passphrase, salt, iterations, keyLength, sha256.New)
+}
+
+// 创建 AES-256-GCM
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
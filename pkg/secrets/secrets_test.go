@@ -0,0 +1,134 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 测试期间清除环境变量，结束后恢复
+func unsetEnv(t *testing.T, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		t.Cleanup(func() { _ = os.Setenv(name, value) })
+	}
+	_ = os.Unsetenv(name)
+}
+
+// 保存包含一个密钥的密钥文件
+func saveStore(t *testing.T, credential Credential) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store, err := Open(path, credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("ym.token", "wxapptoken")
+	if err = store.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	return path
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	key, err := Key(bytes.Repeat([]byte{7}, keyLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, credential := range map[string]Credential{"passphrase": Passphrase("correct horse"), "key": key} {
+		t.Run(name, func(t *testing.T) {
+			path := saveStore(t, credential)
+
+			store, err := Open(path, credential)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if value, err := store.Get("ym.token"); err != nil || value != "wxapptoken" {
+				t.Errorf("Get() = %q, %v, want wxapptoken", value, err)
+			}
+			if _, err = store.Get("zmyy.cookie"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStoreWrongCredential(t *testing.T) {
+	key, err := Key(bytes.Repeat([]byte{7}, keyLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := Key(bytes.Repeat([]byte{8}, keyLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		saved Credential
+		open  Credential
+	}{
+		{name: "wrong passphrase", saved: Passphrase("correct horse"), open: Passphrase("battery staple")},
+		{name: "wrong key", saved: key, open: otherKey},
+		{name: "key for a passphrase file", saved: Passphrase("correct horse"), open: key},
+		{name: "passphrase for a key file", saved: key, open: Passphrase("correct horse")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := saveStore(t, c.saved)
+			if _, err := Open(path, c.open); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Open() error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestKeyLength(t *testing.T) {
+	if _, err := Key(make([]byte, 16)); err == nil {
+		t.Error("Key() error = nil for a 16-byte key")
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 7914 第 11 节 PBKDF2-HMAC-SHA256 的测试向量，确保已有的密钥文件仍能解密
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"
+	if got := hex.EncodeToString(deriveKey([]byte("passwd"), []byte("salt"), 1)); got != want {
+		t.Errorf("deriveKey() = %s, want %s", got, want)
+	}
+}
+
+func TestNewCredentialFromEnv(t *testing.T) {
+	unsetEnv(t, KeyEnv)
+	unsetEnv(t, PassphraseEnv)
+
+	cases := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "matching", input: "correct horse\ncorrect horse\n"},
+		{name: "mismatching", input: "correct horse\ncorrect hose\n", err: "passphrases do not match"},
+		{name: "empty", input: "\n", err: "passphrase must not be empty"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			credential, err := NewCredentialFromEnv(new(bytes.Buffer), strings.NewReader(c.input))
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Errorf("NewCredentialFromEnv() error = %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil || string(credential.passphrase) != "correct horse" {
+				t.Errorf("NewCredentialFromEnv() = %q, %v, want the passphrase", credential.passphrase, err)
+			}
+		})
+	}
+}
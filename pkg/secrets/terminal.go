@@ -0,0 +1,35 @@
+package secrets
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// 输出提示并读取一行不回显的输入，如口令和密钥；输入不是终端或无法关闭回显时与 Prompt 相同
+func PromptSecret(prompt io.Writer, input io.Reader, label string) (string, error) {
+	file, ok := input.(*os.File)
+	if !ok || !IsTerminal(file) {
+		return Prompt(prompt, input, label)
+	}
+
+	restore, err := disableEcho(int(file.Fd()))
+	if err != nil {
+		return Prompt(prompt, input, label)
+	}
+	defer restore()
+
+	value, err := Prompt(prompt, input, label)
+	// 关闭回显时换行符同样不会显示
+	fmt.Fprintln(prompt)
+	return value, err
+}
+
+// 判断文件是否为终端
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,9 @@
+package secrets
+
+import "golang.org/x/sys/unix"
+
+// 读取和设置终端属性的 ioctl 请求
+const (
+	ioctlReadTermios  = unix.TCGETS
+	ioctlWriteTermios = unix.TCSETS
+)
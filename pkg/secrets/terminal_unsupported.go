@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package secrets
+
+import "errors"
+
+// 当前平台不支持关闭终端回显
+var errEchoUnsupported = errors.New("disabling terminal echo is not supported on this platform")
+
+// 当前平台不支持关闭回显，读取时回显输入
+func disableEcho(fd int) (restore func(), err error) {
+	return nil, errEchoUnsupported
+}
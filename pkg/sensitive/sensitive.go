@@ -0,0 +1,60 @@
+package sensitive
+
+import "strings"
+
+// 脱敏后的占位符，日志、录制的请求和输出的配置均使用同一个占位符
+const Mask = "******"
+
+// 需要脱敏的字段、请求参数和请求头，不区分大小写，忽略"_"和"-"
+var keys = map[string]bool{
+	"key":           true,
+	"token":         true,
+	"tk":            true,
+	"cookie":        true,
+	"setcookie":     true,
+	"authorization": true,
+	"zftsl":         true,
+	"idcard":        true,
+	"idcardno":      true,
+	"linkmanid":     true,
+	"linkmanidcard": true,
+	"tel":           true,
+	"mobile":        true,
+	"phone":         true,
+	"birthday":      true,
+	"passphrase":    true,
+	"password":      true,
+}
+
+// 身份信息的字段，医院同样有电话，因此不包括 tel
+var identityKeys = map[string]bool{
+	"idcard":   true,
+	"idcardno": true,
+	"birthday": true,
+}
+
+// 仅在同时包含身份信息时脱敏的字段，知苗易约中接种人的姓名和医院的名称均为 cname
+var personalKeys = map[string]bool{
+	"cname": true,
+}
+
+// 统一字段的大小写和分隔符，如 Set-Cookie 和 set_cookie 均为 setcookie
+func normalize(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// 判断字段是否需要脱敏
+func Key(key string) bool {
+	return keys[normalize(key)]
+}
+
+// 判断字段是否为身份信息
+func Identity(key string) bool {
+	return identityKeys[normalize(key)]
+}
+
+// 判断对象或请求参数中的字段是否需要脱敏，identity 表示其中包含身份信息
+func Field(key string, identity bool) bool {
+	key = normalize(key)
+	return keys[key] || (identity && personalKeys[key])
+}
@@ -1,15 +1,26 @@
 package utils
 
 import (
+	"context"
 	"cupid/pkg/xhttp"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/bitly/go-simplejson"
 )
 
-// 通过百度API获取指定城市的经纬度
-func GetLocation(city string) map[string]interface{} {
+// 获取经纬度的重试策略，网络错误、服务端错误或百度API返回的状态不是 OK 时重试
+var locationRetry = xhttp.RetryPolicy{MaxAttempts: 5, BaseDelay: 200 * time.Millisecond, MaxDelay: 2 * time.Second, Budget: 10 * time.Second, RetryIf: locationFailed}
+
+// 百度API的响应无法解析或状态不是 OK
+func locationFailed(data []byte) bool {
+	dataJSON, err := simplejson.NewJson(data)
+	return err != nil || dataJSON.Get("status").MustString() != "OK"
+}
+
+// 通过百度API获取指定城市的经纬度，失败时返回默认的经纬度
+func GetLocation(ctx context.Context, client *xhttp.Client, city string) map[string]interface{} {
 	location := map[string]interface{}{
 		"lat": json.Number("30.578994"),
 		"lng": json.Number("104.072747"),
@@ -24,29 +35,19 @@ func GetLocation(city string) map[string]interface{} {
 		"key":     "nqQhyG3tAvrD8RmEpGUHhq6kFkTTSGfk",
 	}
 
-	retry := 10
-	for i := 0; i < retry; i++ {
-		data, err := xhttp.Do(url, http.MethodGet, nil, queries, nil)
-		if err != nil {
-			continue
-		}
-
-		dataJSON, err := simplejson.NewJson(data)
-		if err != nil {
-			continue
-		}
-
-		if dataJSON.Get("status").MustString() != "OK" {
-			continue
-		}
-
-		if value, err := dataJSON.Map(); err != nil || value == nil {
-			continue
-		}
+	data, err := client.WithRetry(locationRetry).Do(ctx, url, http.MethodGet, nil, queries, nil)
+	if err != nil {
+		return location
+	}
 
-		location = dataJSON.GetPath("result", "location").MustMap()
+	// 重试用尽时返回最后一次的结果，仍需检查状态
+	if locationFailed(data) {
+		return location
+	}
+	dataJSON, _ := simplejson.NewJson(data)
 
-		break
+	if value := dataJSON.GetPath("result", "location").MustMap(); value != nil {
+		location = value
 	}
 
 	return location
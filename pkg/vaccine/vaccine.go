@@ -0,0 +1,87 @@
+package vaccine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 疫苗的匹配规则
+type Rule struct {
+	Name     string   // 规则名称，展示在嗅探结果中
+	Keywords []string // 关键字，疫苗名称包含任意一个关键字即匹配
+	Regex    string   // 正则表达式，疫苗名称匹配正则表达式即匹配
+	Excludes []string // 排除的关键字，疫苗名称包含任意一个排除的关键字即不匹配
+}
+
+// 默认的匹配规则：九价人乳头瘤病毒疫苗
+var DefaultRules = []Rule{
+	{Name: "九价", Keywords: []string{"九价"}},
+}
+
+// 编译后的匹配规则
+type compiledRule struct {
+	Rule
+	regex *regexp.Regexp
+}
+
+// 疫苗匹配器，按规则的顺序匹配，返回第一个匹配的规则
+type Matcher struct {
+	rules []compiledRule
+}
+
+// 创建疫苗匹配器，规则为空时使用默认的匹配规则
+func NewMatcher(rules []Rule) (*Matcher, error) {
+	if len(rules) <= 0 {
+		rules = DefaultRules
+	}
+
+	matcher := &Matcher{rules: make([]compiledRule, 0, len(rules))}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(rule.Keywords) <= 0 && rule.Regex == "" {
+			return nil, fmt.Errorf("vaccine rule %q: keywords or regex is required", rule.Name)
+		}
+
+		compiled := compiledRule{Rule: rule}
+		if rule.Regex != "" {
+			regex, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("vaccine rule %q: %w", rule.Name, err)
+			}
+			compiled.regex = regex
+		}
+		matcher.rules = append(matcher.rules, compiled)
+	}
+
+	return matcher, nil
+}
+
+// 匹配疫苗名称，返回匹配的规则名称
+func (matcher *Matcher) Match(name string) (string, bool) {
+	for _, rule := range matcher.rules {
+		if rule.match(name) {
+			return rule.Name, true
+		}
+	}
+	return "", false
+}
+
+// 判断疫苗名称是否匹配规则
+func (rule compiledRule) match(name string) bool {
+	for _, exclude := range rule.Excludes {
+		if exclude != "" && strings.Contains(name, exclude) {
+			return false
+		}
+	}
+
+	for _, keyword := range rule.Keywords {
+		if keyword != "" && strings.Contains(name, keyword) {
+			return true
+		}
+	}
+
+	return rule.regex != nil && rule.regex.MatchString(name)
+}
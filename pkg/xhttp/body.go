@@ -0,0 +1,74 @@
+package xhttp
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// 请求体的编码器，为 nil 时不发送请求体
+type Body interface {
+	// 请求体的 Content-Type
+	ContentType() string
+
+	// 编码请求体
+	Encode() ([]byte, error)
+}
+
+// JSON 格式的请求体
+type jsonBody struct {
+	value interface{}
+}
+
+// 创建 JSON 格式的请求体
+func JSON(value interface{}) Body {
+	return jsonBody{value: value}
+}
+
+func (body jsonBody) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (body jsonBody) Encode() ([]byte, error) {
+	return json.Marshal(body.value)
+}
+
+// 表单格式的请求体
+type formBody struct {
+	values url.Values
+}
+
+// 创建表单格式的请求体
+func Form(values map[string]string) Body {
+	form := make(url.Values, len(values))
+	for k, v := range values {
+		form.Set(k, v)
+	}
+	return formBody{values: form}
+}
+
+func (body formBody) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+func (body formBody) Encode() ([]byte, error) {
+	return []byte(body.values.Encode()), nil
+}
+
+// 原样发送的请求体
+type rawBody struct {
+	contentType string
+	data        []byte
+}
+
+// 创建原样发送的请求体，如已序列化的 JSON 字符串
+func Raw(contentType string, data []byte) Body {
+	return rawBody{contentType: contentType, data: data}
+}
+
+func (body rawBody) ContentType() string {
+	return body.contentType
+}
+
+func (body rawBody) Encode() ([]byte, error) {
+	return body.data, nil
+}
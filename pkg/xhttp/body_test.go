@@ -0,0 +1,129 @@
+package xhttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录收到的请求方法、Content-Type 和请求体的本地服务
+type echoServer struct {
+	mutex       sync.Mutex
+	method      string
+	contentType string
+	body        string
+	requests    int
+}
+
+func (server *echoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.method = r.Method
+	server.contentType = r.Header.Get("Content-Type")
+	server.body = string(body)
+	server.requests++
+}
+
+func TestBodyEncoders(t *testing.T) {
+	cases := []struct {
+		name        string
+		body        Body
+		headers     map[string]string
+		contentType string
+		want        string
+	}{
+		{
+			name:        "form",
+			body:        Form(map[string]string{"act": "Save20", "cname": "张三"}),
+			contentType: "application/x-www-form-urlencoded",
+			want:        "act=Save20&cname=%E5%BC%A0%E4%B8%89",
+		},
+		{
+			name:        "json",
+			body:        JSON(map[string]interface{}{"id": 1276}),
+			contentType: "application/json; charset=utf-8",
+			want:        `{"id":1276}`,
+		},
+		{
+			name:        "raw",
+			body:        Raw("text/plain", []byte("hello")),
+			contentType: "text/plain",
+			want:        "hello",
+		},
+		{
+			name:        "header overrides encoder",
+			body:        JSON([]int{1}),
+			headers:     map[string]string{"Content-Type": "application/json"},
+			contentType: "application/json",
+			want:        "[1]",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			echo := new(echoServer)
+			server := httptest.NewServer(echo)
+			defer server.Close()
+
+			if _, err := NewClient(DefaultOptions).Do(context.Background(), server.URL, http.MethodPost, c.headers, nil, c.body); err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if echo.method != http.MethodPost || echo.contentType != c.contentType || echo.body != c.want {
+				t.Errorf("request = %s %q %q, want POST %q %q", echo.method, echo.contentType, echo.body, c.contentType, c.want)
+			}
+		})
+	}
+}
+
+func TestBodyRetried(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(DefaultOptions).WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+	if _, err := client.Do(context.Background(), server.URL, http.MethodPost, nil, nil, Form(map[string]string{"a": "1"})); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if len(bodies) != 2 || bodies[0] != "a=1" || bodies[1] != "a=1" {
+		t.Errorf("bodies = %q, want the body sent on every attempt", bodies)
+	}
+}
+
+func TestGetHasNoBody(t *testing.T) {
+	echo := new(echoServer)
+	server := httptest.NewServer(echo)
+	defer server.Close()
+
+	client := NewClient(DefaultOptions)
+	ctx := context.Background()
+
+	if _, err := client.Do(ctx, server.URL, http.MethodGet, nil, map[string]string{"act": "User"}, nil); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if echo.body != "" || echo.contentType != "" {
+		t.Errorf("GET request body = %q, content type = %q, want none", echo.body, echo.contentType)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		_, err := client.Do(ctx, server.URL, method, nil, nil, Form(map[string]string{"act": "User"}))
+		if err == nil || !strings.Contains(err.Error(), "cannot have a body") {
+			t.Errorf("%s with a body error = %v, want an error", method, err)
+		}
+	}
+	if echo.requests != 1 {
+		t.Errorf("server received %d requests, want 1", echo.requests)
+	}
+}
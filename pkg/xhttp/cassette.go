@@ -0,0 +1,321 @@
+package xhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"cupid/pkg/sensitive"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 回放时未找到匹配的录制
+var ErrCassetteMiss = errors.New("no recorded interaction matches the request")
+
+// 一次请求和响应的录制
+type interaction struct {
+	Key      string           `json:"key"`
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+// 录制的请求，已脱敏
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// 录制的响应，已脱敏
+type recordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// 录制请求和响应的 RoundTripper，每次请求保存为目录中的一个文件
+type recorder struct {
+	next   http.RoundTripper
+	dir    string
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+// 录制请求和响应到指定目录，请求头、请求参数和响应体中的敏感信息会被脱敏
+func (client *Client) Record(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	client.client.Transport = &recorder{
+		next:   client.client.Transport,
+		dir:    dir,
+		counts: make(map[string]int),
+	}
+	return nil
+}
+
+func (r *recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := r.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	recorded := recordRequest(request, requestBody)
+	item := interaction{
+		Key:     recorded.key(),
+		Request: recorded,
+		Response: recordedResponse{
+			StatusCode: response.StatusCode,
+			Header:     redactResponseHeader(response.Header),
+			Body:       string(redactBody(responseBody)),
+		},
+	}
+	if err = r.save(item); err != nil {
+		return nil, fmt.Errorf("unable to record interaction: %w", err)
+	}
+
+	return response, nil
+}
+
+// 保存录制，相同的请求按顺序编号
+func (r *recorder) save(item interaction) error {
+	r.mutex.Lock()
+	index := r.counts[item.Key]
+	r.counts[item.Key]++
+	r.mutex.Unlock()
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(item); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%04d.json", item.Key, index)
+	return ioutil.WriteFile(filepath.Join(r.dir, name), buffer.Bytes(), 0644)
+}
+
+// 回放录制的 RoundTripper，不发起真实的请求
+type replayer struct {
+	mutex        sync.Mutex
+	interactions map[string][]interaction
+	served       map[string]int
+}
+
+// 从指定目录回放录制的响应，相同的请求按录制的顺序返回，用完后重复返回最后一次的响应
+func (client *Client) Replay(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) <= 0 {
+		return fmt.Errorf("no cassettes found in %s", dir)
+	}
+	sort.Strings(files)
+
+	r := &replayer{
+		interactions: make(map[string][]interaction),
+		served:       make(map[string]int),
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var item interaction
+		if err = json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("invalid cassette %s: %w", file, err)
+		}
+		r.interactions[item.Key] = append(r.interactions[item.Key], item)
+	}
+
+	client.client.Transport = r
+	return nil
+}
+
+// 是否正在回放录制的请求，回放时响应头中的 Date 和响应体中的时间戳均为录制时的时间
+func (client *Client) Replaying() bool {
+	_, ok := client.client.Transport.(*replayer)
+	return ok
+}
+
+func (r *replayer) RoundTrip(request *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(request)
+	if err != nil {
+		return nil, err
+	}
+	recorded := recordRequest(request, requestBody)
+	key := recorded.key()
+
+	r.mutex.Lock()
+	items := r.interactions[key]
+	index := r.served[key]
+	if index < len(items)-1 {
+		r.served[key]++
+	}
+	r.mutex.Unlock()
+
+	if len(items) <= 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrCassetteMiss, recorded.Method, recorded.URL)
+	}
+	item := items[index]
+
+	header := item.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", item.Response.StatusCode, http.StatusText(item.Response.StatusCode)),
+		StatusCode:    item.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(item.Response.Body)),
+		ContentLength: int64(len(item.Response.Body)),
+		Request:       request,
+	}, nil
+}
+
+// 读取请求体，并还原以便继续发送
+func readRequestBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// 脱敏请求
+func recordRequest(request *http.Request, body []byte) recordedRequest {
+	u := *request.URL
+	u.RawQuery = redactValues(u.Query()).Encode()
+
+	// 表单格式的请求体与请求参数一样脱敏
+	if mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			body = []byte(redactValues(form).Encode())
+		}
+	} else {
+		body = redactBody(body)
+	}
+
+	return recordedRequest{
+		Method: request.Method,
+		URL:    u.String(),
+		Header: redactHeader(request.Header),
+		Body:   string(body),
+	}
+}
+
+// 脱敏请求参数或表单
+func redactValues(values url.Values) url.Values {
+	identity := false
+	for k := range values {
+		identity = identity || sensitive.Identity(k)
+	}
+	for k := range values {
+		if sensitive.Field(k, identity) {
+			values.Set(k, sensitive.Mask)
+		}
+	}
+	return values
+}
+
+// 根据脱敏后的请求方法、地址和请求体生成录制的键，与随时间变化的请求头无关
+func (request recordedRequest) key() string {
+	sum := sha256.Sum256([]byte(request.Method + " " + request.URL + "\n" + request.Body))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// 脱敏请求头或响应头
+func redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for k := range header {
+		if sensitive.Key(k) {
+			header.Set(k, sensitive.Mask)
+		}
+	}
+	return header
+}
+
+// 脱敏响应头，响应体脱敏后长度会变化，因此移除 Content-Length
+func redactResponseHeader(header http.Header) http.Header {
+	header = redactHeader(header)
+	header.Del("Content-Length")
+	return header
+}
+
+// 脱敏 JSON 格式的请求体或响应体，其他格式原样返回
+func redactBody(body []byte) []byte {
+	if len(body) <= 0 {
+		return body
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+
+	data, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+// 递归脱敏 JSON 中的敏感字段
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		identity := false
+		for k := range v {
+			identity = identity || sensitive.Identity(k)
+		}
+		for k, item := range v {
+			if sensitive.Field(k, identity) {
+				v[k] = sensitive.Mask
+				continue
+			}
+			v[k] = redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package xhttp
+
+import (
+	"cupid/pkg/sensitive"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "linkman",
+			body: `{"status":200,"user":{"cname":"张三","birthday":"1998-01-11","tel":"13800000000","idcard":"510125199801116024","sex":2}}`,
+			want: `{"status":200,"user":{"birthday":"******","cname":"******","idcard":"******","sex":2,"tel":"******"}}`,
+		},
+		{
+			name: "hospitals",
+			body: `{"status":200,"list":[{"id":7,"cname":"成都市武侯区妇幼保健院","tel":"028-85559999"}]}`,
+			want: `{"list":[{"cname":"成都市武侯区妇幼保健院","id":7,"tel":"******"}],"status":200}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := string(redactBody([]byte(c.body))); got != c.want {
+				t.Errorf("redactBody() = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRecordRequestRedactsLinkmanName(t *testing.T) {
+	request, err := http.NewRequest(http.MethodGet, "https://cloud.cn2030.com/sc/wx/HandlerSubscribe.ashx?act=Save20&cname=%E5%BC%A0%E4%B8%89&idcard=510125199801116024&birthday=1998-01-11", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := recordRequest(request, nil)
+	if strings.Contains(recorded.URL, "510125199801116024") || !strings.Contains(recorded.URL, "cname="+url.QueryEscape(sensitive.Mask)) {
+		t.Errorf("recordRequest() url = %s, want linkman fields redacted", recorded.URL)
+	}
+}
+
+func TestRecordRequestRedactsForm(t *testing.T) {
+	body := "cname=%E5%BC%A0%E4%B8%89&idcard=510125199801116024&pid=1"
+	request, err := http.NewRequest(http.MethodPost, "https://cloud.cn2030.com/sc/wx/HandlerSubscribe.ashx?act=Save20", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	recorded := recordRequest(request, []byte(body))
+	want := url.Values{"cname": {sensitive.Mask}, "idcard": {sensitive.Mask}, "pid": {"1"}}.Encode()
+	if recorded.Body != want {
+		t.Errorf("recordRequest() body = %s, want %s", recorded.Body, want)
+	}
+}
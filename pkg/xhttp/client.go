@@ -0,0 +1,129 @@
+package xhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// 响应体超过大小上限
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// 响应的状态码不是 2xx
+type StatusError struct {
+	Method     string // 请求方法
+	URL        string // 请求地址，不含请求参数
+	StatusCode int    // 状态码
+	Body       []byte // 响应体，可能被截断
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// 客户端的配置
+type Options struct {
+	Timeout               time.Duration // 单次请求的总超时时间，包括读取响应体
+	DialTimeout           time.Duration // 建立连接的超时时间
+	TLSHandshakeTimeout   time.Duration // TLS 握手的超时时间
+	ResponseHeaderTimeout time.Duration // 等待响应头的超时时间
+	IdleConnTimeout       time.Duration // 空闲连接保持的时间
+	MaxIdleConnsPerHost   int           // 每个主机保持的空闲连接数
+	MaxResponseSize       int64         // 响应体的大小上限，单位为字节
+}
+
+// 默认的客户端配置
+var DefaultOptions = Options{
+	Timeout:               10 * time.Second,
+	DialTimeout:           5 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ResponseHeaderTimeout: 10 * time.Second,
+	IdleConnTimeout:       90 * time.Second,
+	MaxIdleConnsPerHost:   16,
+	MaxResponseSize:       10 << 20,
+}
+
+// HTTP 客户端，所有请求共享同一个连接池，可被多个协程同时使用
+type Client struct {
+	client          *http.Client
+	maxResponseSize int64
+	retry           RetryPolicy         // 重试策略，默认不重试
+	latency         *Latency            // 各阶段耗时的统计，与使用不同重试策略的客户端共享
+	jar             *Jar                // Cookie Jar，保存服务器下发的 Cookie
+	limiter         *HostLimiter        // 限流器，每次请求（包括重试）前等待，为空时不限流
+	sign            func(*http.Request) // 签名函数，每次请求（包括重试）前调用，用于生成与时间相关的请求头
+}
+
+// 创建客户端，配置中未设置的字段使用默认值
+func NewClient(options Options) *Client {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultOptions.Timeout
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = DefaultOptions.DialTimeout
+	}
+	if options.TLSHandshakeTimeout <= 0 {
+		options.TLSHandshakeTimeout = DefaultOptions.TLSHandshakeTimeout
+	}
+	if options.ResponseHeaderTimeout <= 0 {
+		options.ResponseHeaderTimeout = DefaultOptions.ResponseHeaderTimeout
+	}
+	if options.IdleConnTimeout <= 0 {
+		options.IdleConnTimeout = DefaultOptions.IdleConnTimeout
+	}
+	if options.MaxIdleConnsPerHost <= 0 {
+		options.MaxIdleConnsPerHost = DefaultOptions.MaxIdleConnsPerHost
+	}
+	if options.MaxResponseSize <= 0 {
+		options.MaxResponseSize = DefaultOptions.MaxResponseSize
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   options.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		TLSHandshakeTimeout:   options.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: options.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	jar := NewJar()
+	return &Client{
+		client:          &http.Client{Transport: transport, Timeout: options.Timeout, Jar: jar},
+		maxResponseSize: options.MaxResponseSize,
+		latency:         new(Latency),
+		jar:             jar,
+	}
+}
+
+// 返回使用指定限流器的客户端，与原客户端共享连接池
+func (client *Client) WithLimiter(limiter *HostLimiter) *Client {
+	limited := *client
+	limited.limiter = limiter
+	return &limited
+}
+
+// 返回使用指定签名函数的客户端，与原客户端共享连接池
+func (client *Client) WithSigner(sign func(request *http.Request)) *Client {
+	signed := *client
+	signed.sign = sign
+	return &signed
+}
+
+// 获取客户端的 Cookie Jar
+func (client *Client) Jar() *Jar {
+	return client.jar
+}
+
+// 关闭空闲的连接
+func (client *Client) CloseIdleConnections() {
+	client.client.CloseIdleConnections()
+}
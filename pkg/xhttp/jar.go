@@ -0,0 +1,164 @@
+package xhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 按主机保存 Cookie 的 Cookie Jar，可保存到会话文件中并在下次启动时恢复
+//
+// 只按主机区分 Cookie，忽略 Domain 和 Path，适用于只访问少数几个接口的场景。
+type Jar struct {
+	mutex   sync.Mutex
+	cookies map[string]map[string]*http.Cookie // 主机 -> 名称 -> Cookie
+	seeds   map[string]string                  // 主机 -> 配置文件中的 Cookie
+}
+
+// 会话文件中一个主机的 Cookie
+type sessionHost struct {
+	Seed    string         `json:"seed"`    // 生成会话时配置文件中的 Cookie
+	Cookies []*http.Cookie `json:"cookies"` // 服务器下发的最新 Cookie
+}
+
+// 创建 Cookie Jar
+func NewJar() *Jar {
+	return &Jar{
+		cookies: make(map[string]map[string]*http.Cookie),
+		seeds:   make(map[string]string),
+	}
+}
+
+// 保存服务器通过 Set-Cookie 下发的 Cookie，已过期的 Cookie 会被删除
+func (jar *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jar.mutex.Lock()
+	defer jar.mutex.Unlock()
+
+	jar.setLocked(u.Host, cookies)
+}
+
+// 获取发送到指定地址的 Cookie
+func (jar *Jar) Cookies(u *url.URL) []*http.Cookie {
+	jar.mutex.Lock()
+	defer jar.mutex.Unlock()
+
+	now := time.Now()
+	cookies := make([]*http.Cookie, 0, len(jar.cookies[u.Host]))
+	for name, cookie := range jar.cookies[u.Host] {
+		if expired(cookie, now) {
+			delete(jar.cookies[u.Host], name)
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	return cookies
+}
+
+// 使用配置文件中的 Cookie 初始化指定地址的 Cookie，格式与请求头相同，如 "a=1; b=2"
+func (jar *Jar) Seed(u *url.URL, header string) {
+	request := http.Request{Header: http.Header{"Cookie": []string{header}}}
+
+	jar.mutex.Lock()
+	defer jar.mutex.Unlock()
+
+	jar.seeds[u.Host] = header
+	jar.setLocked(u.Host, request.Cookies())
+}
+
+// 从会话文件中恢复指定地址的 Cookie，文件不存在时忽略
+//
+// 需在 Seed 之后调用：配置文件中的 Cookie 与生成会话时不同，说明已手动更新，此时丢弃会话中的 Cookie。
+func (jar *Jar) Load(u *url.URL, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	hosts := make(map[string]sessionHost)
+	if err = json.Unmarshal(data, &hosts); err != nil {
+		return fmt.Errorf("invalid session file %s: %w", path, err)
+	}
+	session, ok := hosts[u.Host]
+	if !ok {
+		return nil
+	}
+
+	jar.mutex.Lock()
+	defer jar.mutex.Unlock()
+
+	if seed, ok := jar.seeds[u.Host]; ok && seed != session.Seed {
+		return nil
+	}
+	jar.seeds[u.Host] = session.Seed
+	jar.setLocked(u.Host, session.Cookies)
+	return nil
+}
+
+// 将指定地址的 Cookie 保存到会话文件中，其他主机的 Cookie 不会保存
+//
+// 文件中包含登录凭证，因此仅当前用户可读写。
+func (jar *Jar) Save(u *url.URL, path string) error {
+	jar.mutex.Lock()
+	session := sessionHost{Seed: jar.seeds[u.Host], Cookies: make([]*http.Cookie, 0, len(jar.cookies[u.Host]))}
+	now := time.Now()
+	for _, cookie := range jar.cookies[u.Host] {
+		if !expired(cookie, now) {
+			session.Cookies = append(session.Cookies, cookie)
+		}
+	}
+	jar.mutex.Unlock()
+
+	data, err := json.MarshalIndent(map[string]sessionHost{u.Host: session}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	// 先写入临时文件再重命名，避免写入中断时损坏会话文件
+	temp := path + ".tmp"
+	if err = ioutil.WriteFile(temp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// 保存 Cookie，调用方需持有锁
+func (jar *Jar) setLocked(host string, cookies []*http.Cookie) {
+	if jar.cookies[host] == nil {
+		jar.cookies[host] = make(map[string]*http.Cookie)
+	}
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		if cookie.MaxAge > 0 {
+			// 转换为绝对时间，以便保存到会话文件后仍能判断是否过期
+			copied := *cookie
+			copied.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+			copied.MaxAge = 0
+			cookie = &copied
+		}
+		if expired(cookie, now) {
+			delete(jar.cookies[host], cookie.Name)
+			continue
+		}
+		jar.cookies[host][cookie.Name] = cookie
+	}
+}
+
+// 判断 Cookie 是否已过期，没有过期时间的会话 Cookie 不会过期
+func expired(cookie *http.Cookie, now time.Time) bool {
+	if cookie.MaxAge < 0 {
+		return true
+	}
+	return !cookie.Expires.IsZero() && !cookie.Expires.After(now)
+}
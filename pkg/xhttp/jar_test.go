@@ -0,0 +1,139 @@
+package xhttp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var (
+	zmyyURL = &url.URL{Scheme: "https", Host: "cloud.cn2030.com"}
+	ymURL   = &url.URL{Scheme: "https", Host: "miaomiao.scmttec.com"}
+)
+
+// 将发送到指定地址的 Cookie 格式化为 "名称=值"，按名称排序
+func jarCookies(jar *Jar, u *url.URL) []string {
+	cookies := jar.Cookies(u)
+	result := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		result = append(result, cookie.Name+"="+cookie.Value)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestJarSeed(t *testing.T) {
+	jar := NewJar()
+	jar.Seed(zmyyURL, "ASP.NET_SessionId=abc; uid=7")
+
+	if got, want := jarCookies(jar, zmyyURL), []string{"ASP.NET_SessionId=abc", "uid=7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Cookies() = %v, want %v", got, want)
+	}
+	if got := jarCookies(jar, ymURL); len(got) != 0 {
+		t.Errorf("Cookies() of another host = %v, want none", got)
+	}
+
+	// 服务器下发的 Cookie 覆盖同名的 Cookie
+	jar.SetCookies(zmyyURL, []*http.Cookie{{Name: "ASP.NET_SessionId", Value: "def"}})
+	if got, want := jarCookies(jar, zmyyURL), []string{"ASP.NET_SessionId=def", "uid=7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Cookies() = %v, want %v", got, want)
+	}
+}
+
+func TestJarSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "zmyy.json")
+
+	jar := NewJar()
+	jar.Seed(zmyyURL, "ASP.NET_SessionId=abc")
+	jar.SetCookies(zmyyURL, []*http.Cookie{{Name: "ASP.NET_SessionId", Value: "def"}})
+	jar.SetCookies(ymURL, []*http.Cookie{{Name: "tk", Value: "wxapptoken"}})
+	if err := jar.Save(zmyyURL, path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 共享的 Cookie Jar 中其他主机的 Cookie 不会保存
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := make(map[string]sessionHost)
+	if err = json.Unmarshal(data, &hosts); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := hosts[ymURL.Host]; ok || len(hosts) != 1 {
+		t.Errorf("session file = %s, want only %s", data, zmyyURL.Host)
+	}
+
+	cases := []struct {
+		name string
+		seed string
+		want []string
+	}{
+		{name: "same seed", seed: "ASP.NET_SessionId=abc", want: []string{"ASP.NET_SessionId=def"}},
+		{name: "no seed", want: []string{"ASP.NET_SessionId=def"}},
+		{name: "changed seed", seed: "ASP.NET_SessionId=xyz", want: []string{"ASP.NET_SessionId=xyz"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			jar := NewJar()
+			if c.seed != "" {
+				jar.Seed(zmyyURL, c.seed)
+			}
+			if err := jar.Load(zmyyURL, path); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := jarCookies(jar, zmyyURL); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Cookies() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestJarLoadMissingFile(t *testing.T) {
+	jar := NewJar()
+	if err := jar.Load(zmyyURL, filepath.Join(t.TempDir(), "zmyy.json")); err != nil {
+		t.Errorf("Load() error = %v, want nil for a missing file", err)
+	}
+}
+
+func TestJarExpiry(t *testing.T) {
+	jar := NewJar()
+	jar.SetCookies(zmyyURL, []*http.Cookie{
+		{Name: "session", Value: "1"},
+		{Name: "expired", Value: "2", Expires: time.Now().Add(-time.Minute)},
+		{Name: "fresh", Value: "3", Expires: time.Now().Add(time.Hour)},
+		{Name: "max_age", Value: "4", MaxAge: 3600},
+	})
+	if got, want := jarCookies(jar, zmyyURL), []string{"fresh=3", "max_age=4", "session=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Cookies() = %v, want %v", got, want)
+	}
+
+	// 服务器通过 Max-Age < 0 删除 Cookie
+	jar.SetCookies(zmyyURL, []*http.Cookie{{Name: "session", MaxAge: -1}})
+	if got, want := jarCookies(jar, zmyyURL), []string{"fresh=3", "max_age=4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Cookies() = %v, want %v", got, want)
+	}
+
+	// 保存时有效、恢复时已过期的 Cookie 被丢弃，Max-Age 已转换为绝对时间
+	path := filepath.Join(t.TempDir(), "zmyy.json")
+	jar.SetCookies(zmyyURL, []*http.Cookie{{Name: "fresh", Value: "3", Expires: time.Now().Add(50 * time.Millisecond)}})
+	if err := jar.Save(zmyyURL, path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	restored := NewJar()
+	if err := restored.Load(zmyyURL, path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := jarCookies(restored, zmyyURL), []string{"max_age=4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Cookies() after Load() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package xhttp
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// 按主机限流的限流器，不同主机的请求互不影响
+type HostLimiter struct {
+	mutex    sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+// 创建按主机限流的限流器，perSecond 为每个主机每秒的请求数，小于等于 0 时不限流，burst 为允许的突发请求数
+func NewHostLimiter(perSecond float64, burst int) *HostLimiter {
+	limit := rate.Inf
+	if perSecond > 0 {
+		limit = rate.Limit(perSecond)
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &HostLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// 等待直到允许向指定地址发送请求，上下文被取消时返回错误
+func (limiter *HostLimiter) Wait(ctx context.Context, apiURL string) error {
+	host := apiURL
+	if u, err := url.Parse(apiURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return limiter.get(host).Wait(ctx)
+}
+
+// 获取指定主机的限流器
+func (limiter *HostLimiter) get(host string) *rate.Limiter {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	l, ok := limiter.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(limiter.limit, limiter.burst)
+		limiter.limiters[host] = l
+	}
+	return l
+}
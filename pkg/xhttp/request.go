@@ -2,30 +2,77 @@ package xhttp
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 )
 
-// 执行请求
-func Do(apiURL string, method string, headers map[string]string, params map[string]string, body map[string]interface{}) (data []byte, err error) {
-	// Reader
-	var ioReader bytes.Reader
+// 执行请求，上下文被取消或超时时中断请求
+func (client *Client) Do(ctx context.Context, apiURL string, method string, headers map[string]string, params map[string]string, body Body) (data []byte, err error) {
+	data, _, err = client.DoWithHeader(ctx, apiURL, method, headers, params, body)
+	return data, err
+}
 
+// 执行请求，同时返回响应头，失败时按客户端的重试策略重试
+//
+// 状态码不是 2xx 时返回 *StatusError，同时返回响应头；响应体超过大小上限时返回 ErrResponseTooLarge。
+func (client *Client) DoWithHeader(ctx context.Context, apiURL string, method string, headers map[string]string, params map[string]string, body Body) (data []byte, header http.Header, err error) {
+	// 请求体只编码一次，编码失败时不重试
 	if body != nil {
-		// 序列化请求体
-		buffer, err := json.Marshal(body)
+		if method == http.MethodGet || method == http.MethodHead {
+			return nil, nil, fmt.Errorf("%s request cannot have a body", method)
+		}
+
+		buffer, err := body.Encode()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		body = Raw(body.ContentType(), buffer)
+	}
+
+	return client.doWithRetry(ctx, apiURL, method, headers, params, body)
+}
+
+// 执行一次请求，发送前等待限流器
+func (client *Client) do(ctx context.Context, apiURL string, method string, headers map[string]string, params map[string]string, body Body) (data []byte, header http.Header, err error) {
+	if client.limiter != nil {
+		if err = client.limiter.Wait(ctx, apiURL); err != nil {
+			return nil, nil, err
 		}
+	}
 
-		ioReader = *bytes.NewReader(buffer)
+	// 请求体，为空时不发送
+	var ioReader io.Reader
+	if body != nil {
+		buffer, err := body.Encode()
+		if err != nil {
+			return nil, nil, err
+		}
+		ioReader = bytes.NewReader(buffer)
 	}
 
+	// 记录各阶段的耗时
+	trace := new(tracer)
+	ctx = trace.withContext(ctx)
+
+	// 无论成功或失败均记录耗时，失败时同时记录错误
+	defer func() {
+		timing := trace.timing()
+		timing.Err = err
+		client.observe(method, apiURL, timing)
+	}()
+
 	// 初始化请求
-	request, err := http.NewRequest(method, apiURL, &ioReader)
+	request, err := http.NewRequestWithContext(ctx, method, apiURL, ioReader)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// 设置请求体的类型，请求头中已指定时以请求头为准
+	if body != nil && body.ContentType() != "" {
+		request.Header.Set("Content-Type", body.ContentType())
 	}
 
 	// 设置请求头
@@ -42,15 +89,35 @@ func Do(apiURL string, method string, headers map[string]string, params map[stri
 		request.URL.RawQuery = query.Encode()
 	}
 
-	// 初始化客户端
-	client := &http.Client{}
+	// 签名与时间相关，需在限流之后生成
+	if client.sign != nil {
+		client.sign(request)
+	}
 
 	// 发送请求
-	response, err := client.Do(request)
+	response, err := client.client.Do(request)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer response.Body.Close()
 
-	return ioutil.ReadAll(response.Body)
+	// 多读取一个字节，用于判断响应体是否超过大小上限
+	data, err = ioutil.ReadAll(io.LimitReader(response.Body, client.maxResponseSize+1))
+	if err != nil {
+		return nil, response.Header, err
+	}
+	if int64(len(data)) > client.maxResponseSize {
+		return nil, response.Header, ErrResponseTooLarge
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return data, response.Header, &StatusError{
+			Method:     method,
+			URL:        apiURL,
+			StatusCode: response.StatusCode,
+			Body:       data,
+		}
+	}
+
+	return data, response.Header, nil
 }
@@ -0,0 +1,136 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+	"go.uber.org/zap"
+)
+
+// 重试策略，各接口可按需声明不同的策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最多尝试的次数，包括第一次请求，小于等于 1 时不重试
+	BaseDelay   time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	MaxDelay    time.Duration // 单次等待时间的上限
+	Budget      time.Duration // 单次调用的总耗时上限，包括所有的请求和等待，为 0 时不限制
+	JSONField   string        // 响应体中表示业务状态的字段，以"."分隔多级，为空时不检查
+	JSONValues  []string      // 需要重试的业务状态，如繁忙时返回的状态码或提示信息
+
+	// 自定义的重试条件，请求成功但响应体满足条件时重试，如业务状态不是成功，为空时不检查
+	RetryIf func(data []byte) bool
+}
+
+// 不重试
+var NoRetry = RetryPolicy{}
+
+// 返回使用指定重试策略的客户端，与原客户端共享连接池
+func (client *Client) WithRetry(policy RetryPolicy) *Client {
+	retried := *client
+	retried.retry = policy
+	return &retried
+}
+
+// 按重试策略执行请求，重试用尽时返回最后一次的结果
+func (client *Client) doWithRetry(ctx context.Context, apiURL string, method string, headers map[string]string, params map[string]string, body Body) (data []byte, header http.Header, err error) {
+	policy := client.retry
+	if policy.MaxAttempts <= 1 {
+		return client.do(ctx, apiURL, method, headers, params, body)
+	}
+
+	if policy.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Budget)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		data, header, err = client.do(ctx, apiURL, method, headers, params, body)
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil || !policy.retryable(data, err) {
+			return data, header, err
+		}
+
+		// 剩余的预算不足以等待时不再重试
+		delay := policy.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return data, header, err
+		}
+
+		zap.L().Debug("请求失败，等待重试", zap.String("url", apiURL), zap.Int("attempt", attempt), zap.Duration("delay", delay), zap.Error(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return data, header, err
+		case <-timer.C:
+		}
+	}
+}
+
+// 判断请求的结果是否需要重试：网络错误、5xx、429 以及指定的业务状态
+func (policy RetryPolicy) retryable(data []byte, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, ErrResponseTooLarge) {
+			return false
+		}
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+		}
+
+		// 网络错误
+		return true
+	}
+
+	if policy.RetryIf != nil && policy.RetryIf(data) {
+		return true
+	}
+
+	if policy.JSONField == "" || len(policy.JSONValues) <= 0 {
+		return false
+	}
+
+	dataJSON, err := simplejson.NewJson(data)
+	if err != nil {
+		return false
+	}
+	value := dataJSON.GetPath(strings.Split(policy.JSONField, ".")...)
+	if value.Interface() == nil {
+		return false
+	}
+
+	actual := fmt.Sprint(value.Interface())
+	for _, v := range policy.JSONValues {
+		if actual == v {
+			return true
+		}
+	}
+	return false
+}
+
+// 第 attempt 次请求失败后的等待时间：指数退避，并在 [delay/2, delay] 之间随机抖动，避免同时重试
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.BaseDelay
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if policy.MaxDelay > 0 && delay >= policy.MaxDelay {
+			break
+		}
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
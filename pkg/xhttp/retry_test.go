@@ -0,0 +1,42 @@
+package xhttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryIf(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			fmt.Fprint(w, `{"status":"INVALID_REQUEST"}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"OK"}`)
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Millisecond,
+		RetryIf: func(data []byte) bool {
+			return !bytes.Contains(data, []byte(`"OK"`))
+		},
+	}
+	data, err := NewClient(DefaultOptions).WithRetry(policy).Do(context.Background(), server.URL, http.MethodGet, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if string(data) != `{"status":"OK"}` {
+		t.Errorf("data = %s, want the successful response", data)
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
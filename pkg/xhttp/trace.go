@@ -0,0 +1,296 @@
+package xhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 一次请求各阶段的耗时，未经历的阶段为 0，如复用连接时没有 DNS、连接和 TLS
+type Timing struct {
+	DNS     time.Duration // DNS 解析
+	Connect time.Duration // 建立 TCP 连接
+	TLS     time.Duration // TLS 握手
+	TTFB    time.Duration // 从发起请求到收到响应的第一个字节
+	Total   time.Duration // 从发起请求到读取完响应体
+	Reused  bool          // 是否复用了连接
+	Err     error         // 请求失败的原因，成功时为 nil
+}
+
+// 记录一次请求的各阶段时间点，回调可能在不同的协程中执行
+type tracer struct {
+	mutex        sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+// 在上下文中注入 httptrace 的回调
+func (t *tracer) withContext(ctx context.Context) context.Context {
+	t.start = time.Now()
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart, true) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone, false) },
+		ConnectStart: func(string, string) {
+			t.mark(&t.connectStart, true)
+		},
+		ConnectDone: func(string, string, error) {
+			t.mark(&t.connectDone, false)
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart, true) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.mark(&t.tlsDone, false) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.reused = info.Reused
+		},
+		GotFirstResponseByte: func() { t.mark(&t.firstByte, true) },
+	})
+}
+
+// 记录时间点，first 为 true 时只记录第一次，否则记录最后一次，如同时尝试多个地址建立连接
+func (t *tracer) mark(point *time.Time, first bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if first && !point.IsZero() {
+		return
+	}
+	*point = time.Now()
+}
+
+// 计算各阶段的耗时
+func (t *tracer) timing() Timing {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return Timing{
+		DNS:     between(t.dnsStart, t.dnsDone),
+		Connect: between(t.connectStart, t.connectDone),
+		TLS:     between(t.tlsStart, t.tlsDone),
+		TTFB:    between(t.start, t.firstByte),
+		Total:   time.Since(t.start),
+		Reused:  t.reused,
+	}
+}
+
+// 两个时间点之间的耗时，任意一个时间点未记录时为 0
+func between(start time.Time, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// 记录请求的耗时，失败的请求同样记录
+func (client *Client) observe(method string, apiURL string, timing Timing) {
+	zap.L().Debug("请求耗时",
+		zap.String("method", method),
+		zap.String("url", apiURL),
+		zap.Duration("dns", timing.DNS),
+		zap.Duration("connect", timing.Connect),
+		zap.Duration("tls", timing.TLS),
+		zap.Duration("ttfb", timing.TTFB),
+		zap.Duration("total", timing.Total),
+		zap.Bool("reused", timing.Reused),
+		zap.Error(timing.Err),
+	)
+
+	client.latency.Observe(timing)
+}
+
+// 获取客户端所有请求的耗时统计
+func (client *Client) Latency() *Latency {
+	return client.latency
+}
+
+// 耗时直方图的桶的上限
+var latencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// 耗时的直方图
+type Histogram struct {
+	Counts []int         // 各个桶的计数，最后一个桶为超出所有上限的请求
+	Count  int           // 总数
+	Sum    time.Duration // 总耗时
+	Min    time.Duration // 最短耗时
+	Max    time.Duration // 最长耗时
+}
+
+// 记录一次耗时
+func (histogram *Histogram) observe(duration time.Duration) {
+	if histogram.Counts == nil {
+		histogram.Counts = make([]int, len(latencyBuckets)+1)
+	}
+
+	index := len(latencyBuckets)
+	for i, bound := range latencyBuckets {
+		if duration <= bound {
+			index = i
+			break
+		}
+	}
+	histogram.Counts[index]++
+
+	if histogram.Count == 0 || duration < histogram.Min {
+		histogram.Min = duration
+	}
+	if duration > histogram.Max {
+		histogram.Max = duration
+	}
+	histogram.Count++
+	histogram.Sum += duration
+}
+
+// 估算分位数，返回分位数所在的桶的上限，超出所有上限时返回最长耗时
+func (histogram *Histogram) Quantile(q float64) time.Duration {
+	if histogram.Count == 0 {
+		return 0
+	}
+
+	rank := int(q*float64(histogram.Count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	seen := 0
+	for i, count := range histogram.Counts {
+		seen += count
+		if seen >= rank {
+			if i < len(latencyBuckets) && latencyBuckets[i] < histogram.Max {
+				return latencyBuckets[i]
+			}
+			return histogram.Max
+		}
+	}
+	return histogram.Max
+}
+
+// 平均耗时
+func (histogram *Histogram) Mean() time.Duration {
+	if histogram.Count == 0 {
+		return 0
+	}
+	return histogram.Sum / time.Duration(histogram.Count)
+}
+
+// 各阶段耗时的统计，可被多个协程同时写入
+type Latency struct {
+	mutex   sync.Mutex
+	dns     Histogram
+	connect Histogram
+	tls     Histogram
+	ttfb    Histogram
+	total   Histogram
+	reused  int
+	failed  int
+}
+
+// 记录一次请求的耗时，未经历的阶段不计入该阶段的统计
+func (latency *Latency) Observe(timing Timing) {
+	latency.mutex.Lock()
+	defer latency.mutex.Unlock()
+
+	if timing.DNS > 0 {
+		latency.dns.observe(timing.DNS)
+	}
+	if timing.Connect > 0 {
+		latency.connect.observe(timing.Connect)
+	}
+	if timing.TLS > 0 {
+		latency.tls.observe(timing.TLS)
+	}
+	if timing.TTFB > 0 {
+		latency.ttfb.observe(timing.TTFB)
+	}
+	latency.total.observe(timing.Total)
+	if timing.Reused {
+		latency.reused++
+	}
+	if timing.Err != nil {
+		latency.failed++
+	}
+}
+
+// 请求数
+func (latency *Latency) Count() int {
+	latency.mutex.Lock()
+	defer latency.mutex.Unlock()
+
+	return latency.total.Count
+}
+
+// 失败的请求数
+func (latency *Latency) Failed() int {
+	latency.mutex.Lock()
+	defer latency.mutex.Unlock()
+
+	return latency.failed
+}
+
+// 输出各阶段耗时的汇总和总耗时的分布
+func (latency *Latency) String() string {
+	latency.mutex.Lock()
+	defer latency.mutex.Unlock()
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d requests, %d failed, %d reused connections\n", latency.total.Count, latency.failed, latency.reused)
+	fmt.Fprintf(&builder, "%-8s %6s %10s %10s %10s %10s %10s\n", "phase", "count", "min", "mean", "p50", "p90", "max")
+
+	phases := []struct {
+		name      string
+		histogram *Histogram
+	}{
+		{"dns", &latency.dns},
+		{"connect", &latency.connect},
+		{"tls", &latency.tls},
+		{"ttfb", &latency.ttfb},
+		{"total", &latency.total},
+	}
+	for _, phase := range phases {
+		h := phase.histogram
+		fmt.Fprintf(&builder, "%-8s %6d %10s %10s %10s %10s %10s\n", phase.name, h.Count,
+			round(h.Min), round(h.Mean()), round(h.Quantile(0.5)), round(h.Quantile(0.9)), round(h.Max))
+	}
+
+	// 总耗时的分布
+	if latency.total.Count > 0 {
+		builder.WriteString("total distribution:\n")
+		for i, count := range latency.total.Counts {
+			label := "> " + latencyBuckets[len(latencyBuckets)-1].String()
+			if i < len(latencyBuckets) {
+				label = "<= " + latencyBuckets[i].String()
+			}
+			bar := strings.Repeat("#", count*40/latency.total.Count)
+			fmt.Fprintf(&builder, "%10s %6d %s\n", label, count, bar)
+		}
+	}
+
+	return builder.String()
+}
+
+// 保留到 0.1 毫秒的耗时，本地的 DNS 和连接通常不足 1 毫秒
+func round(duration time.Duration) time.Duration {
+	return duration.Round(100 * time.Microsecond)
+}
@@ -0,0 +1,54 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLatencyObservesFailedRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			fmt.Fprint(w, strings.Repeat("x", 64))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer server.Close()
+
+	options := DefaultOptions
+	options.MaxResponseSize = 16
+	client := NewClient(options)
+	ctx := context.Background()
+
+	if _, err := client.Do(ctx, server.URL+"/ok", http.MethodGet, nil, nil, nil); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if _, err := client.Do(ctx, server.URL+"/large", http.MethodGet, nil, nil, nil); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("Do() error = %v, want ErrResponseTooLarge", err)
+	}
+	var statusError *StatusError
+	if _, err := client.Do(ctx, server.URL+"/missing", http.MethodGet, nil, nil, nil); !errors.As(err, &statusError) {
+		t.Fatalf("Do() error = %v, want *StatusError", err)
+	}
+
+	// 连接失败时同样记录耗时
+	server.Close()
+	if _, err := client.Do(ctx, server.URL+"/ok", http.MethodGet, nil, nil, nil); err == nil {
+		t.Fatal("Do() error = nil, want a connection error")
+	}
+
+	if got := client.Latency().Count(); got != 4 {
+		t.Errorf("Latency().Count() = %d, want 4", got)
+	}
+	if got := client.Latency().Failed(); got != 3 {
+		t.Errorf("Latency().Failed() = %d, want 3", got)
+	}
+}
@@ -31,6 +31,9 @@ const (
 
 	// 固定请求头
 	ZMYYReferer = "https://servicewechat.com/wx2c7f0f3c30d99445/72/page-frame.html"
+
+	// 默认的会话文件，保存服务器下发的最新 Cookie
+	ZMYYSessionFile = "./sessions/zmyy.json"
 )
 
 // 直辖市
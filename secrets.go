@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cupid/pkg/secrets"
+	"cupid/pkg/utils"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// 管理加密的密钥文件
+func secretsCommand() *cli.Command {
+	fileFlag := &cli.StringFlag{
+		Name:    "file",
+		Aliases: []string{`f`},
+		Usage:   "指定密钥文件",
+		Value:   secrets.DefaultFile,
+	}
+
+	return &cli.Command{
+		Name:  "secrets",
+		Usage: "管理加密的密钥，配置项中以 secret://名称 引用",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "set",
+				Usage:     "设置密钥，未指定值时从标准输入读取",
+				ArgsUsage: "<name> [value]",
+				Flags: []cli.Flag{
+					fileFlag,
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{`y`},
+						Usage:   "密钥文件不存在时直接创建，不再确认",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 1 || c.NArg() > 2 {
+						return cli.Exit("usage: cupid secrets set <name> [value]", 1)
+					}
+
+					// 密钥文件不存在时确认是否创建，避免路径有误时使用新的口令创建另一个密钥文件
+					if !c.Bool("yes") && !utils.FileExist(c.String("file")) {
+						answer, err := secrets.Prompt(os.Stderr, os.Stdin, fmt.Sprintf("%s does not exist, create it? [y/N]: ", c.String("file")))
+						if err != nil {
+							return cli.Exit(err.Error(), 1)
+						}
+						if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+							return cli.Exit("aborted", 1)
+						}
+					}
+
+					store, err := openSecrets(c.String("file"))
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					name, value := c.Args().Get(0), c.Args().Get(1)
+					if c.NArg() < 2 {
+						// 从标准输入读取，避免密钥出现在命令行历史中
+						if value, err = secrets.PromptSecret(os.Stderr, os.Stdin, fmt.Sprintf("Value of %s: ", name)); err != nil {
+							return cli.Exit(err.Error(), 1)
+						}
+					}
+
+					store.Set(name, value)
+					if err = store.Save(); err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					fmt.Printf("%s saved, use %s%s in the configuration file\n", name, secrets.Scheme, name)
+					return nil
+				},
+			},
+			{
+				Name:      "get",
+				Usage:     "获取密钥",
+				ArgsUsage: "<name>",
+				Flags:     []cli.Flag{fileFlag},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return cli.Exit("usage: cupid secrets get <name>", 1)
+					}
+
+					store, err := openSecrets(c.String("file"))
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					value, err := store.Get(c.Args().First())
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					fmt.Println(value)
+					return nil
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "列出所有密钥的名称",
+				Flags: []cli.Flag{fileFlag},
+				Action: func(c *cli.Context) error {
+					store, err := openSecrets(c.String("file"))
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					for _, name := range store.Names() {
+						fmt.Println(name)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// 解锁并打开密钥文件，文件不存在时保存时创建
+func openSecrets(file string) (*secrets.Store, error) {
+	credentialFromEnv := secrets.CredentialFromEnv
+	if !utils.FileExist(file) {
+		// 新建密钥文件时口令需输入两次
+		credentialFromEnv = secrets.NewCredentialFromEnv
+	}
+	credential, err := credentialFromEnv(os.Stderr, os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return secrets.Open(file, credential)
+}